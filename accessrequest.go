@@ -0,0 +1,295 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/cryptvault-cloud/helper"
+	"github.com/urfave/cli/v2"
+)
+
+const AccessRequestVersion = 1
+
+// AccessRequest is a signed bundle someone sends to an admin to get registered at a vault.
+// The signature is created with the private key belonging to PublicKey over the request without signature.
+type AccessRequest struct {
+	Version   int       `json:"version"`
+	VaultId   string    `json:"vaultId"`
+	Name      string    `json:"name"`
+	PublicKey string    `json:"publicKey"`
+	Rights    []string  `json:"rights"`
+	Note      string    `json:"note,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+	Signature string    `json:"signature,omitempty"`
+}
+
+func (a *AccessRequest) signedMessage() (string, error) {
+	unsigned := *a
+	unsigned.Signature = ""
+	content, err := json.Marshal(unsigned)
+	return string(content), err
+}
+
+func (a *AccessRequest) sign(key *ecdsa.PrivateKey) error {
+	message, err := a.signedMessage()
+	if err != nil {
+		return err
+	}
+	a.Signature, err = helper.Sign(key, message)
+	return err
+}
+
+// verify checks the signature and returns the public key of the request.
+func (a *AccessRequest) verify() (*ecdsa.PublicKey, error) {
+	if a.Version != AccessRequestVersion {
+		return nil, fmt.Errorf("unsupported access request version %d", a.Version)
+	}
+	pubKey, err := helper.GetPublicKeyFromB64String(a.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+	message, err := a.signedMessage()
+	if err != nil {
+		return nil, err
+	}
+	valid, err := helper.Verify(pubKey, message, a.Signature)
+	if err != nil {
+		return nil, err
+	}
+	if !valid {
+		return nil, fmt.Errorf("signature of access request is invalid")
+	}
+	return pubKey, nil
+}
+
+func validateRights(rights []string) error {
+	var err error = nil
+	for _, one := range rights {
+		if !ValuePatternRegex.MatchString(one) {
+			err = errors.Join(err, fmt.Errorf("right %s have to match right string pattern: %s", one, helper.ValuePatternRegexStr))
+		}
+	}
+	return err
+}
+
+func GetLocalRequestAccessCommand(runner *Runner) *cli.Command {
+	return &cli.Command{
+		Name:        "request-access",
+		Usage:       "Create a signed access request to send to someone who can add you to the vault",
+		Description: "A new local identity is created if it does not exist yet. The request contains its public key and is signed with its private key.",
+		Action:      runner.audited(runner.LocalRequestAccess),
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:     CliRequestAccessName,
+				Usage:    "Name of the identity",
+				Required: true,
+			},
+			&cli.StringSliceFlag{
+				Name:     CliRequestAccessRights,
+				Aliases:  []string{"r"},
+				Usage:    "Requested rights something like (r)VALUES.app.>",
+				Required: true,
+				Action: func(ctx *cli.Context, s []string) error {
+					return validateRights(s)
+				},
+			},
+			&cli.StringFlag{
+				Name:  CliRequestAccessNote,
+				Usage: "Note for the admin why access is needed",
+			},
+			&cli.StringFlag{
+				Name:  CliRequestAccessOut,
+				Usage: "File to write the request to, printed if not set",
+			},
+		},
+	}
+}
+
+func GetApproveCommand(pRunner *ProtectedRunner) *cli.Command {
+	return &cli.Command{
+		Name:        "approve",
+		Usage:       "Register an identity by a signed access request",
+		Description: "The signature of the request is verified and requested rights can be trimmed before the identity is registered.",
+		Action:      pRunner.audited(pRunner.Approve),
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:     CliApproveBundle,
+				Usage:    "File with the access request",
+				Required: true,
+			},
+			&cli.StringSliceFlag{
+				Name:  CliApproveRemoveRight,
+				Usage: "Requested right which should not be granted",
+			},
+			&cli.BoolFlag{
+				Name:  CliApproveYes,
+				Usage: "Grant all requested rights without asking",
+			},
+		},
+	}
+}
+
+func (r *Runner) LocalRequestAccess(c *cli.Context) error {
+	name := c.String(CliRequestAccessName)
+	vaultName, err := r.fileHandler.SelectedVault()
+	if err != nil {
+		return err
+	}
+	vaultId, err := r.vaultIdByName(vaultName)
+	if err != nil {
+		return err
+	}
+	key, err := r.identityKey(vaultName, name)
+	if err != nil {
+		privKey, _, err := r.api.GetNewIdentityKeyPair()
+		if err != nil {
+			return err
+		}
+		if err := r.saveLocalIdentity(vaultName, vaultId, name, privKey); err != nil {
+			return err
+		}
+		fmt.Printf("New local identity created at %s\n", r.fileHandler.FullPath(identityFolder(vaultName, name)))
+		key = privKey
+	}
+	b64PubKey, err := helper.GetB64FromPublicKey(&key.PublicKey)
+	if err != nil {
+		return err
+	}
+
+	request := &AccessRequest{
+		Version:   AccessRequestVersion,
+		VaultId:   vaultId,
+		Name:      name,
+		PublicKey: b64PubKey,
+		Rights:    c.StringSlice(CliRequestAccessRights),
+		Note:      c.String(CliRequestAccessNote),
+		CreatedAt: time.Now().UTC(),
+	}
+	if err := request.sign(key); err != nil {
+		return err
+	}
+	content, err := json.MarshalIndent(request, "", "  ")
+	if err != nil {
+		return err
+	}
+	out := c.String(CliRequestAccessOut)
+	if out == "" {
+		fmt.Println(string(content))
+		return nil
+	}
+	if err := os.WriteFile(out, append(content, '\n'), 0644); err != nil {
+		return err
+	}
+	fmt.Printf("Access request saved at %s, send it to someone who can add you to the vault\n", out)
+	fmt.Printf("Fingerprint to verify the key over an other channel: %s\n", fingerprintOrUnknown(&key.PublicKey))
+	return nil
+}
+
+// saveLocalIdentity writes key, key.pub and id of a new identity to the workspace.
+func (r *Runner) saveLocalIdentity(vaultName, vaultId, name string, key *ecdsa.PrivateKey) error {
+	b64PubKey, err := helper.GetB64FromPublicKey(&key.PublicKey)
+	if err != nil {
+		return err
+	}
+	b64PrivKey, err := helper.GetB64FromPrivateKey(key)
+	if err != nil {
+		return err
+	}
+	identityId, err := identityIdOfKey(&key.PublicKey, vaultId)
+	if err != nil {
+		return err
+	}
+	err = errors.Join(r.fileHandler.SaveTextToFile(fmt.Sprintf("%s/identity/%s/key.pub", vaultName, name), b64PubKey), err)
+	err = errors.Join(r.fileHandler.SaveTextToFile(fmt.Sprintf("%s/identity/%s/key", vaultName, name), b64PrivKey), err)
+	err = errors.Join(r.fileHandler.SaveTextToFile(fmt.Sprintf("%s/identity/%s/id", vaultName, name), identityId), err)
+	return err
+}
+
+func (r *ProtectedRunner) Approve(c *cli.Context) error {
+	content, err := os.ReadFile(c.String(CliApproveBundle))
+	if err != nil {
+		return err
+	}
+	request := &AccessRequest{}
+	if err := json.Unmarshal(content, request); err != nil {
+		return err
+	}
+	pubKey, err := request.verify()
+	if err != nil {
+		return err
+	}
+	if request.VaultId != *r.vaultId {
+		return fmt.Errorf("access request is for vault %s not for %s", request.VaultId, *r.vaultId)
+	}
+	if err := validateRights(request.Rights); err != nil {
+		return err
+	}
+	identityId, err := identityIdOfKey(pubKey, *r.vaultId)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Signature is valid\nName: %s\nID: %s\nFingerprint: %s\nRequested at: %s\n", request.Name, identityId, fingerprintOrUnknown(pubKey), request.CreatedAt.Format(time.RFC3339))
+	fmt.Println("Compare the fingerprint with the requester over an other channel before you approve.")
+	if request.Note != "" {
+		fmt.Printf("Note: %s\n", request.Note)
+	}
+	fmt.Printf("Requested rights:\n\t%s\n", strings.Join(request.Rights, "\n\t"))
+
+	removeRights := c.StringSlice(CliApproveRemoveRight)
+	for _, right := range removeRights {
+		if !helper.Includes(request.Rights, func(s string) bool { return s == right }) {
+			return fmt.Errorf("right %s to remove was not requested", right)
+		}
+	}
+	rights := helper.Filter(request.Rights, func(s string) bool {
+		return !helper.Includes(removeRights, func(remove string) bool { return remove == s })
+	})
+
+	if !c.Bool(CliApproveYes) {
+		if !isTerminal(os.Stdin) {
+			return withExitCode(ExitUsage, fmt.Errorf("stdin is not a terminal, use --%s to approve without asking", CliApproveYes))
+		}
+		granted := make([]string, 0, len(rights))
+		for _, right := range rights {
+			ok, err := confirm(fmt.Sprintf("Grant %s?", right), true)
+			if err != nil {
+				return err
+			}
+			if ok {
+				granted = append(granted, right)
+			}
+		}
+		rights = granted
+		ok, err := confirm(fmt.Sprintf("Register %s with %d rights?", request.Name, len(rights)), false)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return fmt.Errorf("aborted")
+		}
+	}
+
+	rightInputs, err := getRightInputs(rights)
+	if err != nil {
+		return err
+	}
+	res, err := r.api.AddIdentity(request.Name, pubKey, rightInputs)
+	if err != nil {
+		return err
+	}
+	err = r.api.SyncValues(res.IdentityId)
+	if err != nil {
+		if err2 := r.api.DeleteIdentity(res.IdentityId); err2 != nil {
+			return errors.Join(err, fmt.Errorf("failed to rollback new identity %s: %w", res.IdentityId, err2))
+		}
+		return errors.Join(err, fmt.Errorf("new identity %s was removed again", res.IdentityId))
+	}
+	fmt.Printf("Identity %s was registered with rights:\n\t%s\n", request.Name, strings.Join(rights, "\n\t"))
+	return nil
+}
@@ -0,0 +1,284 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/user"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/cryptvault-cloud/vault-cli/logger"
+	"github.com/urfave/cli/v2"
+)
+
+const (
+	AuditFile = "audit.log"
+	// AuditHeadFile holds count and hash of the last entry, without it removing entries from the end would keep a valid chain.
+	AuditHeadFile = "audit.head"
+
+	AuditSuccess = "success"
+	AuditFailure = "failure"
+)
+
+// auditTargetFlags are flags naming the value or identity a command works on.
+var auditTargetFlags = []string{"name", "id", CliApproveBundle, CliReplicateToVault}
+
+// AuditEntry is one line of the audit log. Hash is the SHA-256 of the previous hash and the entry without hash,
+// so a changed or removed line breaks the chain. Entries cut from the end are detected by the head file,
+// someone who can rewrite both files can still rewrite the whole log.
+type AuditEntry struct {
+	Time       time.Time `json:"time"`
+	User       string    `json:"user"`
+	Host       string    `json:"host"`
+	VaultId    string    `json:"vaultId,omitempty"`
+	IdentityId string    `json:"identityId,omitempty"`
+	Command    string    `json:"command"`
+	Target     string    `json:"target,omitempty"`
+	Outcome    string    `json:"outcome"`
+	Error      string    `json:"error,omitempty"`
+	Prev       string    `json:"prev"`
+	Hash       string    `json:"hash"`
+}
+
+func (e *AuditEntry) computeHash() (string, error) {
+	unhashed := *e
+	unhashed.Hash = ""
+	content, err := json.Marshal(unhashed)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+func GetLocalAuditCommand(runner *Runner) *cli.Command {
+	return &cli.Command{
+		Name:        "audit",
+		Usage:       "Audit log of all mutating commands run with this workspace",
+		Description: "Each entry contains the hash of the previous one and audit.head the count and hash of the last entry, verify detects changed, removed or cut off entries as long as not both files are rewritten.",
+		Subcommands: []*cli.Command{
+			{
+				Name:   "show",
+				Usage:  "Print audit entries",
+				Action: runner.LocalAuditShow,
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:  CliAuditSince,
+						Usage: "Only entries newer than this f.e. 7d or 12h",
+					},
+				},
+			},
+			{
+				Name:   "verify",
+				Usage:  "Check the hash chain of the audit log",
+				Action: runner.LocalAuditVerify,
+			},
+		},
+	}
+}
+
+func osUser() string {
+	if current, err := user.Current(); err == nil {
+		return current.Username
+	}
+	return os.Getenv("USER")
+}
+
+// commandPath returns the full command like "protected delete value".
+func commandPath(c *cli.Context) string {
+	names := make([]string, 0)
+	for _, ctx := range c.Lineage() {
+		// the root command is named like the app itself
+		if ctx.Command != nil && ctx.Command.Name != "" && ctx.Command.Name != c.App.Name {
+			names = append([]string{ctx.Command.Name}, names...)
+		}
+	}
+	return strings.Join(names, " ")
+}
+
+func auditTarget(c *cli.Context) string {
+	targets := make([]string, 0)
+	for _, name := range auditTargetFlags {
+		if c.IsSet(name) {
+			targets = append(targets, fmt.Sprintf("%s=%s", name, c.String(name)))
+		}
+	}
+	return strings.Join(targets, " ")
+}
+
+// readAudit returns all entries of the audit log, an empty list if there is none yet.
+func (r *Runner) readAudit() ([]*AuditEntry, error) {
+	content, err := r.fileHandler.ReadTextFile(AuditFile)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []*AuditEntry{}, nil
+		}
+		return nil, err
+	}
+	entries := make([]*AuditEntry, 0)
+	for i, line := range strings.Split(content, "\n") {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		entry := &AuditEntry{}
+		if err := json.Unmarshal([]byte(line), entry); err != nil {
+			return nil, fmt.Errorf("audit log line %d is damaged: %w", i+1, err)
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// audit appends an entry for a finished command, failures to write are only logged
+// to not hide the outcome of the command itself.
+func (r *Runner) audit(c *cli.Context, vaultId, identityId string, actionErr error) {
+	if _, noWorkspace := r.fileHandler.(*FileHandlerMock); noWorkspace || isDryRun(c) {
+		return
+	}
+	log := logger.GetWithStructAndFunc("Runner", "audit")
+	entry := &AuditEntry{
+		Time:       time.Now().UTC(),
+		User:       osUser(),
+		VaultId:    vaultId,
+		IdentityId: identityId,
+		Command:    commandPath(c),
+		Target:     auditTarget(c),
+		Outcome:    AuditSuccess,
+	}
+	entry.Host, _ = os.Hostname()
+	if actionErr != nil {
+		entry.Outcome = AuditFailure
+		entry.Error = logger.RedactString(actionErr.Error())
+	}
+	entries, readErr := r.readAudit()
+	if readErr != nil {
+		log.Warnw("audit log can not be read, entry is written without chain", "error", readErr)
+	} else if len(entries) > 0 {
+		entry.Prev = entries[len(entries)-1].Hash
+	}
+	var err error
+	entry.Hash, err = entry.computeHash()
+	if err == nil {
+		var line []byte
+		line, err = json.Marshal(entry)
+		if err == nil {
+			err = r.fileHandler.AppendTextToFile(AuditFile, string(line)+"\n")
+		}
+	}
+	if err == nil && readErr == nil {
+		err = r.fileHandler.SaveTextToFile(AuditHeadFile, auditHead(len(entries)+1, entry.Hash))
+	}
+	if err != nil {
+		log.Errorw("audit entry could not be written", "command", entry.Command, "error", err)
+	}
+}
+
+func auditHead(count int, hash string) string {
+	return fmt.Sprintf("%d %s\n", count, hash)
+}
+
+// audited records the outcome of a command which changes a vault or the workspace.
+func (r *Runner) audited(action cli.ActionFunc) cli.ActionFunc {
+	return func(c *cli.Context) error {
+		err := action(c)
+		r.audit(c, "", "", err)
+		return err
+	}
+}
+
+// audited records the outcome of a replication together with the destination vault and the identity writing to it.
+func (r *ReplicateRunner) audited(action cli.ActionFunc) cli.ActionFunc {
+	return func(c *cli.Context) error {
+		err := action(c)
+		r.runner.audit(c, r.dstVaultId, r.dstIdentityId, err)
+		return err
+	}
+}
+
+// audited records the outcome of a protected command together with vault and acting identity.
+func (r *ProtectedRunner) audited(action cli.ActionFunc) cli.ActionFunc {
+	return func(c *cli.Context) error {
+		err := action(c)
+		identityId, _ := identityIdOfKey(&r.privateKey.PublicKey, *r.vaultId)
+		r.runner.audit(c, *r.vaultId, identityId, err)
+		return err
+	}
+}
+
+func (r *Runner) LocalAuditShow(c *cli.Context) error {
+	entries, err := r.readAudit()
+	if err != nil {
+		return err
+	}
+	since := time.Time{}
+	if c.String(CliAuditSince) != "" {
+		age, err := parseAge(c.String(CliAuditSince))
+		if err != nil {
+			return err
+		}
+		since = time.Now().Add(-age)
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "TIME\tUSER\tVAULT\tIDENTITY\tCOMMAND\tTARGET\tOUTCOME")
+	for _, entry := range entries {
+		if entry.Time.Before(since) {
+			continue
+		}
+		outcome := entry.Outcome
+		if entry.Error != "" {
+			outcome = fmt.Sprintf("%s: %s", entry.Outcome, entry.Error)
+		}
+		fmt.Fprintf(w, "%s\t%s@%s\t%s\t%s\t%s\t%s\t%s\n", entry.Time.Local().Format(time.DateTime), entry.User, entry.Host, shortId(entry.VaultId), shortId(entry.IdentityId), entry.Command, entry.Target, outcome)
+	}
+	return w.Flush()
+}
+
+func shortId(id string) string {
+	if id == "" {
+		return "-"
+	}
+	if len(id) > 12 {
+		return id[:12]
+	}
+	return id
+}
+
+func (r *Runner) LocalAuditVerify(c *cli.Context) error {
+	entries, err := r.readAudit()
+	if err != nil {
+		return err
+	}
+	prev := ""
+	for i, entry := range entries {
+		hash, err := entry.computeHash()
+		if err != nil {
+			return err
+		}
+		if entry.Prev != prev {
+			fmt.Printf("FAIL  entry %d does not follow entry %d, entries were removed or reordered\n", i+1, i)
+			return silentExit(ExitError)
+		}
+		if hash != entry.Hash {
+			fmt.Printf("FAIL  entry %d was changed\n", i+1)
+			return silentExit(ExitError)
+		}
+		prev = entry.Hash
+	}
+	head, err := r.fileHandler.ReadTextFile(AuditHeadFile)
+	switch {
+	case err != nil && !os.IsNotExist(err):
+		return err
+	case err != nil && len(entries) > 0:
+		fmt.Printf("FAIL  %s is missing, entries at the end may have been removed\n", AuditHeadFile)
+		return silentExit(ExitError)
+	case err == nil && head != auditHead(len(entries), prev):
+		fmt.Printf("FAIL  last entry does not match %s, entries at the end were removed or added without the cli\n", AuditHeadFile)
+		return silentExit(ExitError)
+	}
+	fmt.Printf("OK    %d entries, chain is valid\n", len(entries))
+	return nil
+}
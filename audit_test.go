@@ -0,0 +1,79 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/urfave/cli/v2"
+)
+
+// auditLines writes three audit entries to a new workspace and returns the runner and the log lines.
+func auditLines(t *testing.T) (*Runner, []string) {
+	t.Helper()
+	r := &Runner{fileHandler: &FileHandler{RootPath: t.TempDir()}}
+	c := cli.NewContext(&cli.App{Name: "vault-cli"}, flag.NewFlagSet("test", flag.ContinueOnError), nil)
+	r.audit(c, "vault-id", "identity-a", nil)
+	r.audit(c, "vault-id", "identity-b", errors.New("denied"))
+	r.audit(c, "vault-id", "identity-c", nil)
+	content, err := r.fileHandler.ReadTextFile(AuditFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSuffix(content, "\n"), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("got %d audit lines, expected 3", len(lines))
+	}
+	return r, lines
+}
+
+func TestAuditVerify(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(t *testing.T, r *Runner, lines []string) []string
+		valid  bool
+	}{
+		{name: "unchanged", change: func(t *testing.T, r *Runner, lines []string) []string { return lines }, valid: true},
+		{name: "edited outcome", change: func(t *testing.T, r *Runner, lines []string) []string {
+			lines[1] = strings.Replace(lines[1], `"outcome":"failure"`, `"outcome":"success"`, 1)
+			return lines
+		}},
+		{name: "edited identity", change: func(t *testing.T, r *Runner, lines []string) []string {
+			lines[0] = strings.Replace(lines[0], "identity-a", "identity-x", 1)
+			return lines
+		}},
+		{name: "removed entry", change: func(t *testing.T, r *Runner, lines []string) []string {
+			return []string{lines[0], lines[2]}
+		}},
+		{name: "reordered entries", change: func(t *testing.T, r *Runner, lines []string) []string {
+			return []string{lines[1], lines[0], lines[2]}
+		}},
+		{name: "cut last entry", change: func(t *testing.T, r *Runner, lines []string) []string {
+			return lines[:2]
+		}},
+		{name: "removed head", change: func(t *testing.T, r *Runner, lines []string) []string {
+			if err := r.fileHandler.DeleteFolder(AuditHeadFile); err != nil {
+				t.Fatal(err)
+			}
+			return lines
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, lines := auditLines(t)
+			lines = tt.change(t, r, lines)
+			if err := os.WriteFile(r.fileHandler.FullPath(AuditFile), []byte(strings.Join(lines, "\n")+"\n"), 0600); err != nil {
+				t.Fatal(err)
+			}
+			err := r.LocalAuditVerify(nil)
+			if tt.valid && err != nil {
+				t.Fatalf("expected valid chain, got %v", err)
+			}
+			if !tt.valid && !errors.Is(err, errSilent) {
+				t.Fatalf("expected a broken chain, got %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,540 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/cryptvault-cloud/helper"
+	"github.com/urfave/cli/v2"
+	"golang.org/x/crypto/scrypt"
+)
+
+const (
+	BackupVersion = 1
+	backupMagic   = "CVBAK"
+
+	ConflictSkip      = "skip"
+	ConflictOverwrite = "overwrite"
+	ConflictRename    = "rename"
+)
+
+// backupHeader is stored unencrypted in front of the archive and authenticated as additional data.
+type backupHeader struct {
+	Version int    `json:"version"`
+	Kdf     string `json:"kdf"`
+	N       int    `json:"n"`
+	R       int    `json:"r"`
+	P       int    `json:"p"`
+	Salt    []byte `json:"salt"`
+	Nonce   []byte `json:"nonce"`
+}
+
+type backupArchive struct {
+	CreatedAt    time.Time     `json:"createdAt"`
+	CurrentVault string        `json:"currentVault,omitempty"`
+	Vaults       []backupVault `json:"vaults"`
+}
+
+// backupVault holds the files of one vault folder, all file maps are keyed by the path relative to their folder.
+type backupVault struct {
+	Name       string                       `json:"name"`
+	VaultId    string                       `json:"vaultId"`
+	Operator   map[string]string            `json:"operator,omitempty"`
+	Identities map[string]map[string]string `json:"identities"`
+	// Values are only set by an export before the vault is deleted at the server.
+	Values []backupValue `json:"values,omitempty"`
+}
+
+type backupValue struct {
+	Name  string `json:"name"`
+	Type  string `json:"type"`
+	Value string `json:"value"`
+}
+
+func backupPassphraseFlag() cli.Flag {
+	return &cli.StringFlag{
+		Name:    CliBackupPassphrase,
+		EnvVars: []string{getFlagEnvByFlagName(CliBackupPassphrase)},
+		Usage:   "Passphrase to encrypt or decrypt the backup, asked at the terminal if not set",
+	}
+}
+
+func GetLocalBackupCommand(runner *Runner) *cli.Command {
+	return &cli.Command{
+		Name:   "backup",
+		Usage:  "Write local vaults and identities to one encrypted file",
+		Action: runner.LocalBackup,
+		Flags: []cli.Flag{
+			backupPassphraseFlag(),
+			&cli.StringFlag{
+				Name:     CliBackupOut,
+				Usage:    "File to write the backup to",
+				Required: true,
+			},
+			&cli.StringSliceFlag{
+				Name:  CliBackupVault,
+				Usage: "Vaults to include, all if not set",
+			},
+			&cli.StringSliceFlag{
+				Name:  CliBackupIdentity,
+				Usage: "Identities to include, all if not set. Use operator for the operator key",
+			},
+		},
+	}
+}
+
+func GetLocalRestoreCommand(runner *Runner) *cli.Command {
+	return &cli.Command{
+		Name:   "restore",
+		Usage:  "Restore local vaults and identities from a backup",
+		Action: runner.audited(runner.LocalRestore),
+		Flags: []cli.Flag{
+			backupPassphraseFlag(),
+			&cli.StringFlag{
+				Name:     CliBackupIn,
+				Usage:    "Backup file to restore",
+				Required: true,
+			},
+			&cli.StringFlag{
+				Name:  CliBackupConflict,
+				Usage: "What to do if a vault or identity already exists with other content: skip, overwrite or rename",
+				Value: ConflictSkip,
+			},
+			&cli.BoolFlag{
+				Name:  CliBackupPreview,
+				Usage: "Only show what would be restored",
+			},
+		},
+	}
+}
+
+// backupKdfParams pins the scrypt parameters per backup version,
+// the header is read before it is authenticated and must not choose the cost of the key derivation.
+var backupKdfParams = map[int][3]int{
+	1: {1 << 15, 8, 1},
+}
+
+func backupKey(passphrase string, header *backupHeader) ([]byte, error) {
+	if header.Kdf != "scrypt" {
+		return nil, fmt.Errorf("unsupported key derivation %s", header.Kdf)
+	}
+	return scrypt.Key([]byte(passphrase), header.Salt, header.N, header.R, header.P, 32)
+}
+
+func encryptBackup(passphrase string, archive *backupArchive) ([]byte, error) {
+	plain := bytes.Buffer{}
+	gz := gzip.NewWriter(&plain)
+	if err := json.NewEncoder(gz).Encode(archive); err != nil {
+		return nil, err
+	}
+	if err := gz.Close(); err != nil {
+		return nil, err
+	}
+
+	params := backupKdfParams[BackupVersion]
+	header := &backupHeader{Version: BackupVersion, Kdf: "scrypt", N: params[0], R: params[1], P: params[2], Salt: make([]byte, 16), Nonce: make([]byte, 12)}
+	if _, err := rand.Read(header.Salt); err != nil {
+		return nil, err
+	}
+	if _, err := rand.Read(header.Nonce); err != nil {
+		return nil, err
+	}
+	key, err := backupKey(passphrase, header)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	headerJson, err := json.Marshal(header)
+	if err != nil {
+		return nil, err
+	}
+	out := bytes.Buffer{}
+	out.WriteString(backupMagic + "\n")
+	out.Write(headerJson)
+	out.WriteString("\n")
+	out.Write(gcm.Seal(nil, header.Nonce, plain.Bytes(), headerJson))
+	return out.Bytes(), nil
+}
+
+func decryptBackup(passphrase string, content []byte) (*backupArchive, error) {
+	reader := bufio.NewReader(bytes.NewReader(content))
+	magic, err := reader.ReadString('\n')
+	if err != nil || strings.TrimSpace(magic) != backupMagic {
+		return nil, fmt.Errorf("not a cryptvault backup")
+	}
+	headerJson, err := reader.ReadBytes('\n')
+	if err != nil {
+		return nil, fmt.Errorf("backup header is damaged")
+	}
+	headerJson = bytes.TrimSuffix(headerJson, []byte("\n"))
+	header := &backupHeader{}
+	if err := json.Unmarshal(headerJson, header); err != nil {
+		return nil, fmt.Errorf("backup header is damaged: %w", err)
+	}
+	params, ok := backupKdfParams[header.Version]
+	if !ok {
+		return nil, fmt.Errorf("unsupported backup version %d", header.Version)
+	}
+	if header.N != params[0] || header.R != params[1] || header.P != params[2] {
+		return nil, fmt.Errorf("backup header is damaged: unexpected key derivation parameters")
+	}
+	key, err := backupKey(passphrase, header)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(header.Nonce) != gcm.NonceSize() {
+		return nil, fmt.Errorf("backup header is damaged: invalid nonce")
+	}
+	sealed, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+	plain, err := gcm.Open(nil, header.Nonce, sealed, headerJson)
+	if err != nil {
+		return nil, fmt.Errorf("wrong passphrase or damaged backup")
+	}
+	gz, err := gzip.NewReader(bytes.NewReader(plain))
+	if err != nil {
+		return nil, err
+	}
+	archive := &backupArchive{}
+	if err := json.NewDecoder(gz).Decode(archive); err != nil {
+		return nil, err
+	}
+	return archive, nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// readFolder returns all files below folder by their relative path, nil if the folder does not exist.
+func (r *Runner) readFolder(folder string) (map[string]string, error) {
+	files, err := r.fileHandler.ListFiles(folder)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return nil, nil
+	}
+	result := make(map[string]string, len(files))
+	for _, f := range files {
+		content, err := r.fileHandler.ReadTextFile(fmt.Sprintf("%s/%s", folder, f))
+		if err != nil {
+			return nil, err
+		}
+		result[f] = content
+	}
+	return result, nil
+}
+
+// backupVault reads the key files of a local vault, only identities accepted by includeIdentity are included.
+func (r *Runner) backupVault(vaultName string, includeIdentity func(string) bool) (*backupVault, error) {
+	vaultId, err := r.vaultIdByName(vaultName)
+	if err != nil {
+		return nil, err
+	}
+	vault := &backupVault{Name: vaultName, VaultId: vaultId, Identities: make(map[string]map[string]string)}
+	if includeIdentity(OperatorIdentityName) {
+		vault.Operator, err = r.readFolder(identityFolder(vaultName, OperatorIdentityName))
+		if err != nil {
+			return nil, err
+		}
+	}
+	identityFiles, err := r.readFolder(fmt.Sprintf("%s/identity", vaultName))
+	if err != nil {
+		return nil, err
+	}
+	for f, content := range identityFiles {
+		name, file, found := strings.Cut(f, "/")
+		if !found || !includeIdentity(name) {
+			continue
+		}
+		if vault.Identities[name] == nil {
+			vault.Identities[name] = make(map[string]string)
+		}
+		vault.Identities[name][file] = content
+	}
+	return vault, nil
+}
+
+func (r *Runner) LocalBackup(c *cli.Context) error {
+	passphrase, err := readPassphrase(c, true)
+	if err != nil {
+		return err
+	}
+	if len(passphrase) < 8 {
+		return fmt.Errorf("passphrase have to be at least 8 characters")
+	}
+	vaults, err := r.fileHandler.AvailableVaults()
+	if err != nil {
+		return err
+	}
+	if selected := c.StringSlice(CliBackupVault); len(selected) > 0 {
+		for _, v := range selected {
+			if !helper.Includes(vaults, func(s string) bool { return s == v }) {
+				return fmt.Errorf("vault %s not found at local workspace", v)
+			}
+		}
+		vaults = selected
+	}
+	identities := c.StringSlice(CliBackupIdentity)
+	includeIdentity := func(name string) bool {
+		return len(identities) == 0 || helper.Includes(identities, func(s string) bool { return s == name })
+	}
+
+	archive := &backupArchive{CreatedAt: time.Now().UTC(), Vaults: make([]backupVault, 0, len(vaults))}
+	if current, err := r.fileHandler.SelectedVault(); err == nil {
+		archive.CurrentVault = current
+	}
+	identityCount := 0
+	for _, vaultName := range vaults {
+		vault, err := r.backupVault(vaultName, includeIdentity)
+		if err != nil {
+			return err
+		}
+		identityCount += len(vault.Identities)
+		archive.Vaults = append(archive.Vaults, *vault)
+	}
+
+	content, err := encryptBackup(passphrase, archive)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(c.String(CliBackupOut), content, 0600); err != nil {
+		return err
+	}
+	fmt.Printf("Backup of %d vaults and %d identities saved at %s\n", len(archive.Vaults), identityCount, c.String(CliBackupOut))
+	return nil
+}
+
+// restoreStep is one folder or file which will be written by a restore.
+type restoreStep struct {
+	target string
+	files  map[string]string
+	// replace removes the target folder before writing
+	replace bool
+}
+
+func sameFiles(a, b map[string]string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for k, v := range a {
+		if b[k] != v {
+			return false
+		}
+	}
+	return true
+}
+
+// uniqueFolder returns the first of base, base-2, base-3 ... which does not exist at the workspace.
+func (r *Runner) uniqueFolder(base string, planned map[string]bool) (string, error) {
+	candidate := base
+	for i := 2; ; i++ {
+		existing, err := r.readFolder(candidate)
+		if err != nil {
+			return "", err
+		}
+		if existing == nil && !planned[candidate] {
+			return candidate, nil
+		}
+		candidate = fmt.Sprintf("%s-%d", base, i)
+	}
+}
+
+// planRestoreFolder decides how the files of one identity or operator folder are restored.
+// A folder which can not be read is an error, it must not be restored over as if it was missing.
+func (r *Runner) planRestoreFolder(target, renameTarget, conflict string, files map[string]string, planned map[string]bool) (*restoreStep, string, error) {
+	existing, err := r.readFolder(target)
+	if err != nil {
+		return nil, "", err
+	}
+	switch {
+	case existing == nil:
+		return &restoreStep{target: target, files: files}, "restore", nil
+	case sameFiles(existing, files):
+		return nil, "unchanged", nil
+	case conflict == ConflictOverwrite:
+		return &restoreStep{target: target, files: files, replace: true}, "overwrite", nil
+	case conflict == ConflictRename:
+		renamed, err := r.uniqueFolder(renameTarget, planned)
+		if err != nil {
+			return nil, "", err
+		}
+		return &restoreStep{target: renamed, files: files}, fmt.Sprintf("rename to %s", renamed), nil
+	default:
+		return nil, "skip, already exists", nil
+	}
+}
+
+// planRestore prints what will be restored and returns the steps to do so.
+func (r *Runner) planRestore(archive *backupArchive, conflict string) ([]*restoreStep, error) {
+	steps := make([]*restoreStep, 0)
+	planned := make(map[string]bool)
+	add := func(description string, step *restoreStep, result string) {
+		fmt.Printf("%-40s %s\n", description, result)
+		if step != nil {
+			steps = append(steps, step)
+			planned[step.target] = true
+		}
+	}
+	for _, vault := range archive.Vaults {
+		target := vault.Name
+		description := fmt.Sprintf("vault %s", vault.Name)
+		existingId, err := r.vaultIdByName(vault.Name)
+		switch {
+		case err != nil:
+			add(description, nil, "restore")
+		case existingId == vault.VaultId:
+			add(description, nil, "unchanged")
+		case conflict == ConflictOverwrite:
+			add(description, nil, "overwrite vault id")
+		case conflict == ConflictRename:
+			target, err = r.uniqueFolder(vault.Name+"-restored", planned)
+			if err != nil {
+				return nil, err
+			}
+			add(description, nil, fmt.Sprintf("other vault id, rename to %s", target))
+		default:
+			add(description, nil, "skip, other vault with this name exists")
+			continue
+		}
+		add(fmt.Sprintf("%s vault id", vault.Name), &restoreStep{target: target, files: map[string]string{"vaultId": vault.VaultId}}, vault.VaultId)
+		if len(vault.Values) > 0 {
+			add(fmt.Sprintf("%s values", vault.Name), nil, fmt.Sprintf("%d exported values, not restored to the server", len(vault.Values)))
+		}
+
+		if vault.Operator != nil {
+			step, result, err := r.planRestoreFolder(identityFolder(target, OperatorIdentityName), identityFolder(target, "operator-restored"), conflict, vault.Operator, planned)
+			if err != nil {
+				return nil, err
+			}
+			add(fmt.Sprintf("%s operator", vault.Name), step, result)
+		}
+		names := make([]string, 0, len(vault.Identities))
+		for name := range vault.Identities {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			// not identityFolder, a local identity named operator is not the operator key
+			identityTarget := fmt.Sprintf("%s/identity/%s", target, name)
+			step, result, err := r.planRestoreFolder(identityTarget, identityTarget+"-restored", conflict, vault.Identities[name], planned)
+			if err != nil {
+				return nil, err
+			}
+			add(fmt.Sprintf("%s identity %s", vault.Name, name), step, result)
+		}
+	}
+	return steps, nil
+}
+
+// validateArchive rejects names and files which would be written outside of their folder at the workspace.
+func validateArchive(archive *backupArchive) error {
+	validFiles := func(description string, files map[string]string) error {
+		for f := range files {
+			if err := validWorkspaceFile(f); err != nil {
+				return fmt.Errorf("backup contains %s with %w", description, err)
+			}
+		}
+		return nil
+	}
+	if archive.CurrentVault != "" {
+		if err := validWorkspaceName(archive.CurrentVault); err != nil {
+			return fmt.Errorf("backup contains current vault with %w", err)
+		}
+	}
+	for _, vault := range archive.Vaults {
+		if err := validWorkspaceName(vault.Name); err != nil {
+			return fmt.Errorf("backup contains vault with %w", err)
+		}
+		if err := validFiles(fmt.Sprintf("operator of vault %s", vault.Name), vault.Operator); err != nil {
+			return err
+		}
+		for name, files := range vault.Identities {
+			if err := validWorkspaceName(name); err != nil {
+				return fmt.Errorf("backup contains identity of vault %s with %w", vault.Name, err)
+			}
+			if err := validFiles(fmt.Sprintf("identity %s of vault %s", name, vault.Name), files); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (r *Runner) LocalRestore(c *cli.Context) error {
+	conflict := c.String(CliBackupConflict)
+	if conflict != ConflictSkip && conflict != ConflictOverwrite && conflict != ConflictRename {
+		return fmt.Errorf("unknown conflict handling %s", conflict)
+	}
+	content, err := os.ReadFile(c.String(CliBackupIn))
+	if err != nil {
+		return err
+	}
+	passphrase, err := readPassphrase(c, false)
+	if err != nil {
+		return err
+	}
+	archive, err := decryptBackup(passphrase, content)
+	if err != nil {
+		return err
+	}
+	if err := validateArchive(archive); err != nil {
+		return err
+	}
+	fmt.Printf("Backup created at %s\n", archive.CreatedAt.Format(time.RFC3339))
+	steps, err := r.planRestore(archive, conflict)
+	if err != nil {
+		return err
+	}
+	if c.Bool(CliBackupPreview) {
+		fmt.Print("Preview only, nothing was restored\n")
+		return nil
+	}
+
+	for _, step := range steps {
+		if step.replace {
+			err = errors.Join(r.fileHandler.DeleteFolder(step.target), err)
+		}
+		for f, content := range step.files {
+			err = errors.Join(r.fileHandler.SaveTextToFile(fmt.Sprintf("%s/%s", step.target, f), content), err)
+		}
+	}
+	if _, selectErr := r.fileHandler.SelectedVault(); selectErr != nil && archive.CurrentVault != "" {
+		err = errors.Join(r.fileHandler.SaveTextToFile("/currentVault.txt", archive.CurrentVault), err)
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Print("Restore finished\n")
+	return nil
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testPassphrase = "correct horse battery"
+
+func testArchive() *backupArchive {
+	return &backupArchive{
+		CreatedAt:    time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC),
+		CurrentVault: "prod",
+		Vaults: []backupVault{{
+			Name:       "prod",
+			VaultId:    "vault-id",
+			Operator:   map[string]string{"key": "operator-key", "key.pub": "operator-pub", "previous/key": "old-key"},
+			Identities: map[string]map[string]string{"ci": {"key": "ci-key", "key.pub": "ci-pub", "id": "ci-id"}},
+		}},
+	}
+}
+
+// tamperBackup replaces the header of an encrypted backup by the result of change.
+func tamperBackup(t *testing.T, content []byte, change func(header *backupHeader)) []byte {
+	t.Helper()
+	parts := bytes.SplitN(content, []byte("\n"), 3)
+	if len(parts) != 3 {
+		t.Fatal("backup has no header")
+	}
+	header := &backupHeader{}
+	if err := json.Unmarshal(parts[1], header); err != nil {
+		t.Fatal(err)
+	}
+	change(header)
+	headerJson, err := json.Marshal(header)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return bytes.Join([][]byte{parts[0], headerJson, parts[2]}, []byte("\n"))
+}
+
+func TestBackupEncryptDecrypt(t *testing.T) {
+	archive := testArchive()
+	content, err := encryptBackup(testPassphrase, archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(content, []byte("operator-key")) {
+		t.Fatal("backup contains plain key")
+	}
+	decrypted, err := decryptBackup(testPassphrase, content)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(decrypted, archive) {
+		t.Fatalf("decrypted archive %+v, expected %+v", decrypted, archive)
+	}
+}
+
+func TestBackupDecryptFailures(t *testing.T) {
+	content, err := encryptBackup(testPassphrase, testArchive())
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name       string
+		passphrase string
+		content    []byte
+		err        string
+	}{
+		{name: "wrong passphrase", passphrase: "wrong passphrase", content: content, err: "wrong passphrase"},
+		{name: "no backup", passphrase: testPassphrase, content: []byte("something else\n"), err: "not a cryptvault backup"},
+		{name: "scrypt cost raised", passphrase: testPassphrase, content: tamperBackup(t, content, func(h *backupHeader) { h.N = 1 << 30 }), err: "key derivation parameters"},
+		{name: "scrypt cost lowered", passphrase: testPassphrase, content: tamperBackup(t, content, func(h *backupHeader) { h.R = 1 }), err: "key derivation parameters"},
+		{name: "unknown version", passphrase: testPassphrase, content: tamperBackup(t, content, func(h *backupHeader) { h.Version = 99 }), err: "unsupported backup version"},
+		{name: "unknown kdf", passphrase: testPassphrase, content: tamperBackup(t, content, func(h *backupHeader) { h.Kdf = "none" }), err: "unsupported key derivation"},
+		{name: "short nonce", passphrase: testPassphrase, content: tamperBackup(t, content, func(h *backupHeader) { h.Nonce = h.Nonce[:4] }), err: "invalid nonce"},
+		{name: "changed salt", passphrase: testPassphrase, content: tamperBackup(t, content, func(h *backupHeader) { h.Salt[0] ^= 1 }), err: "wrong passphrase or damaged backup"},
+		{name: "changed nonce", passphrase: testPassphrase, content: tamperBackup(t, content, func(h *backupHeader) { h.Nonce[0] ^= 1 }), err: "wrong passphrase or damaged backup"},
+		{name: "changed ciphertext", passphrase: testPassphrase, content: append(bytes.Clone(content[:len(content)-1]), content[len(content)-1]^1), err: "wrong passphrase or damaged backup"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := decryptBackup(tt.passphrase, tt.content)
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Fatalf("got error %v, expected %q", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestValidateArchive(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(a *backupArchive)
+		valid  bool
+	}{
+		{name: "valid", change: func(a *backupArchive) {}, valid: true},
+		{name: "empty vault name", change: func(a *backupArchive) { a.Vaults[0].Name = "" }},
+		{name: "vault name ..", change: func(a *backupArchive) { a.Vaults[0].Name = ".." }},
+		{name: "vault name with slash", change: func(a *backupArchive) { a.Vaults[0].Name = "../x" }},
+		{name: "hidden vault name", change: func(a *backupArchive) { a.Vaults[0].Name = ".trash" }},
+		{name: "current vault outside", change: func(a *backupArchive) { a.CurrentVault = "../x" }},
+		{name: "identity name ..", change: func(a *backupArchive) { a.Vaults[0].Identities[".."] = map[string]string{"key": "k"} }},
+		{name: "empty identity name", change: func(a *backupArchive) { a.Vaults[0].Identities[""] = map[string]string{"key": "k"} }},
+		{name: "identity file outside", change: func(a *backupArchive) { a.Vaults[0].Identities["ci"]["../../key"] = "k" }},
+		{name: "operator file absolute", change: func(a *backupArchive) { a.Vaults[0].Operator["/etc/passwd"] = "k" }},
+		{name: "operator file not clean", change: func(a *backupArchive) { a.Vaults[0].Operator["previous/../../key"] = "k" }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			archive := testArchive()
+			tt.change(archive)
+			err := validateArchive(archive)
+			if tt.valid && err != nil {
+				t.Fatalf("expected valid archive, got %s", err)
+			}
+			if !tt.valid && err == nil {
+				t.Fatal("expected an error")
+			}
+		})
+	}
+}
+
+func TestPlanRestoreIdentityNamedOperator(t *testing.T) {
+	r := &Runner{fileHandler: &FileHandler{RootPath: t.TempDir()}}
+	archive := testArchive()
+	archive.Vaults[0].Identities[OperatorIdentityName] = map[string]string{"key": "identity-key"}
+	steps, err := r.planRestore(archive, ConflictSkip)
+	if err != nil {
+		t.Fatal(err)
+	}
+	targets := make(map[string]map[string]string)
+	for _, step := range steps {
+		if _, ok := targets[step.target]; ok {
+			t.Fatalf("target %s is planned twice", step.target)
+		}
+		targets[step.target] = step.files
+	}
+	if targets["prod/operator"]["key"] != "operator-key" {
+		t.Fatalf("operator key is restored as %q", targets["prod/operator"]["key"])
+	}
+	if targets["prod/identity/operator"]["key"] != "identity-key" {
+		t.Fatalf("identity named operator is restored as %q", targets["prod/identity/operator"]["key"])
+	}
+}
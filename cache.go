@@ -0,0 +1,232 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/cryptvault-cloud/helper"
+	"github.com/cryptvault-cloud/vault-cli/logger"
+	"github.com/urfave/cli/v2"
+)
+
+const CacheFolder = ".cache"
+
+// cachedValue is a value encrypted to the public key of the identity which fetched it.
+type cachedValue struct {
+	Name      string    `json:"name"`
+	FetchedAt time.Time `json:"fetchedAt"`
+	Value     string    `json:"value"`
+}
+
+type cacheEntry struct {
+	path       string
+	vaultId    string
+	identityId string
+	value      *cachedValue
+}
+
+func valueCacheFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.BoolFlag{
+			Name:    CliGetValueCache,
+			EnvVars: []string{getFlagEnvByFlagName(CliGetValueCache)},
+			Usage:   "Cache the value encrypted at the workspace and use it if the server is unreachable",
+		},
+		&cli.StringFlag{
+			Name:    CliGetValueCacheMaxAge,
+			EnvVars: []string{getFlagEnvByFlagName(strings.ReplaceAll(CliGetValueCacheMaxAge, "-", "_"))},
+			Value:   "24h",
+			Usage:   "Max age of a cached value to be used, f.e. 7d or 12h",
+		},
+	}
+}
+
+func GetLocalCacheCommand(runner *Runner) *cli.Command {
+	return &cli.Command{
+		Name:  "cache",
+		Usage: "Offline cache of values fetched by get value --cache",
+		Subcommands: []*cli.Command{
+			{
+				Name:   "ls",
+				Usage:  "List cached values",
+				Action: runner.LocalCacheList,
+			},
+			{
+				Name:   "clear",
+				Usage:  "Delete cached values",
+				Action: runner.LocalCacheClear,
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:  CliCacheVaultId,
+						Usage: "Only values of this vault",
+					},
+					&cli.StringFlag{
+						Name:  CliCacheOlderThan,
+						Usage: "Only values fetched before this age f.e. 7d or 12h",
+					},
+				},
+			},
+		},
+	}
+}
+
+func valueCachePath(vaultId, identityId, name string) string {
+	sum := sha256.Sum256([]byte(name))
+	return fmt.Sprintf("%s/%s/%s/%s", CacheFolder, vaultId, identityId, hex.EncodeToString(sum[:]))
+}
+
+// cacheValue stores plain encrypted to the public key of the identity.
+func (r *ProtectedRunner) cacheValue(name, plain string) error {
+	identityId, err := identityIdOfKey(&r.privateKey.PublicKey, *r.vaultId)
+	if err != nil {
+		return err
+	}
+	encrypted, err := helper.Encrypt(&r.privateKey.PublicKey, plain)
+	if err != nil {
+		return err
+	}
+	content, err := json.Marshal(&cachedValue{Name: name, FetchedAt: time.Now().UTC(), Value: string(encrypted)})
+	if err != nil {
+		return err
+	}
+	return r.runner.fileHandler.SaveTextToFile(valueCachePath(*r.vaultId, identityId, name), string(content))
+}
+
+// cachedValue returns the decrypted value if it is cached and not older than maxAge.
+func (r *ProtectedRunner) cachedValue(name string, maxAge time.Duration) (string, time.Time, error) {
+	identityId, err := identityIdOfKey(&r.privateKey.PublicKey, *r.vaultId)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	content, err := r.runner.fileHandler.ReadTextFile(valueCachePath(*r.vaultId, identityId, name))
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("value %s is not cached", name)
+	}
+	cached := &cachedValue{}
+	if err := json.Unmarshal([]byte(content), cached); err != nil {
+		return "", time.Time{}, err
+	}
+	if cached.Name != name {
+		return "", time.Time{}, fmt.Errorf("value %s is not cached", name)
+	}
+	if time.Since(cached.FetchedAt) > maxAge {
+		return "", cached.FetchedAt, fmt.Errorf("cached value %s is older than %s", name, maxAge)
+	}
+	plain, err := helper.Decrypt(r.privateKey, cached.Value)
+	if err != nil {
+		return "", cached.FetchedAt, err
+	}
+	return string(plain), cached.FetchedAt, nil
+}
+
+// valueWithCache fetches a value from the server and falls back to the cache if the server is unreachable.
+func (r *ProtectedRunner) valueWithCache(c *cli.Context, name string, fetch func() (string, error)) (string, error) {
+	if !c.Bool(CliGetValueCache) {
+		return fetch()
+	}
+	log := logger.GetWithStructAndFunc("ProtectedRunner", "valueWithCache")
+	maxAge, err := parseAge(c.String(CliGetValueCacheMaxAge))
+	if err != nil {
+		return "", err
+	}
+	value, err := fetch()
+	if err == nil {
+		if cacheErr := r.cacheValue(name, value); cacheErr != nil {
+			log.Warnw("value could not be cached", "name", name, "error", cacheErr)
+		}
+		return value, nil
+	}
+	if exitCode(err) != ExitNetwork {
+		return "", err
+	}
+	cached, fetchedAt, cacheErr := r.cachedValue(name, maxAge)
+	if cacheErr != nil {
+		log.Debugw("no usable cached value", "name", name, "error", cacheErr)
+		return "", err
+	}
+	log.Warnw("server is unreachable, cached value is used", "name", name, "fetchedAt", fetchedAt, "error", err)
+	fmt.Fprintf(os.Stderr, "Warning: server is unreachable, using cached value of %s fetched at %s\n", name, fetchedAt.Local().Format(time.DateTime))
+	return cached, nil
+}
+
+func (r *Runner) cacheEntries() ([]*cacheEntry, error) {
+	files, err := r.fileHandler.ListFiles(CacheFolder)
+	if err != nil {
+		// no cache folder yet
+		return []*cacheEntry{}, nil
+	}
+	entries := make([]*cacheEntry, 0, len(files))
+	for _, f := range files {
+		parts := strings.Split(f, "/")
+		if len(parts) != 3 {
+			continue
+		}
+		entry := &cacheEntry{path: fmt.Sprintf("%s/%s", CacheFolder, f), vaultId: parts[0], identityId: parts[1], value: &cachedValue{}}
+		content, err := r.fileHandler.ReadTextFile(entry.path)
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal([]byte(content), entry.value); err != nil {
+			entry.value.Name = "damaged"
+		}
+		entries = append(entries, entry)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].vaultId != entries[j].vaultId {
+			return entries[i].vaultId < entries[j].vaultId
+		}
+		return entries[i].value.Name < entries[j].value.Name
+	})
+	return entries, nil
+}
+
+func (r *Runner) LocalCacheList(c *cli.Context) error {
+	entries, err := r.cacheEntries()
+	if err != nil {
+		return err
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "VAULT\tIDENTITY\tNAME\tFETCHED")
+	for _, entry := range entries {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", entry.vaultId, shortId(entry.identityId), entry.value.Name, entry.value.FetchedAt.Local().Format(time.DateTime))
+	}
+	return w.Flush()
+}
+
+func (r *Runner) LocalCacheClear(c *cli.Context) error {
+	entries, err := r.cacheEntries()
+	if err != nil {
+		return err
+	}
+	deadline := time.Now()
+	if olderThan := c.String(CliCacheOlderThan); olderThan != "" {
+		age, err := parseAge(olderThan)
+		if err != nil {
+			return err
+		}
+		deadline = deadline.Add(-age)
+	}
+	vaultId := c.String(CliCacheVaultId)
+	cleared := 0
+	for _, entry := range entries {
+		if vaultId != "" && entry.vaultId != vaultId {
+			continue
+		}
+		if !entry.value.FetchedAt.Before(deadline) {
+			continue
+		}
+		if err := r.fileHandler.DeleteFolder(entry.path); err != nil {
+			return err
+		}
+		cleared++
+	}
+	fmt.Printf("%d cached values deleted\n", cleared)
+	return nil
+}
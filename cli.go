@@ -3,7 +3,6 @@ package main
 import (
 	"errors"
 	"fmt"
-	"log"
 	"net/http"
 	"os"
 	"path"
@@ -15,7 +14,6 @@ import (
 	"github.com/cryptvault-cloud/helper"
 	"github.com/cryptvault-cloud/vault-cli/logger"
 	"github.com/urfave/cli/v2"
-	"github.com/vektah/gqlparser/v2/gqlerror"
 )
 
 const (
@@ -49,9 +47,105 @@ const (
 	CliSaveFilePath               = "save_file_path"
 	CliDeleteIdentityId           = "id"
 	CliDeleteValueName            = "name"
+	CliDeleteYes                  = "yes"
+	CliDeleteVaultInventory       = "inventory"
+	CliDeleteVaultPurge           = "purge"
+	CliDeleteVaultExport          = "export"
+	CliHttpTimeout                = "timeout"
+	CliHttpRetries                = "retries"
+	CliHttpProxy                  = "proxy"
+	CliHttpCaFile                 = "ca-file"
+	CliHttpClientCert             = "client-cert"
+	CliHttpClientKey              = "client-key"
+	CliTrace                      = "trace"
+	CliTraceFile                  = "trace-file"
+	CliOutput                     = "output"
+	CliLogFormat                  = "log-format"
+	CliLogFile                    = "log-file"
+	CliLogMaxSize                 = "log-max-size"
+	CliLogMaxBackups              = "log-max-backups"
+	CliAuditSince                 = "since"
+	CliGetValueCache              = "cache"
+	CliGetValueCacheMaxAge        = "cache-max-age"
+	CliCacheVaultId               = "vault-id"
+	CliCacheOlderThan             = "older-than"
+	CliWatchName                  = "name"
+	CliWatchInterval              = "interval"
+	CliWatchExec                  = "exec"
+	CliWatchOut                   = "out"
+	CliWatchOnce                  = "once"
+	CliServeListen                = "listen"
+	CliServeAllowPrefix           = "allow-prefix"
+	CliServeCacheTTL              = "cache-ttl"
+	CliServeSocketMode            = "socket-mode"
+	CliServeInsecureListen        = "insecure-listen"
+	CliK8sName                    = "name"
+	CliK8sNamespace               = "namespace"
+	CliK8sType                    = "type"
+	CliK8sMap                     = "map"
+	CliK8sFromPrefix              = "from-prefix"
+	CliK8sLabel                   = "label"
+	CliK8sAnnotation              = "annotation"
+	CliK8sExpandJson              = "expand-json"
+	CliK8sOut                     = "out"
+	CliDockerPrefix               = "prefix"
+	CliDryRun                     = "dry-run"
+	CliTrashId                    = "id"
+	CliTrashOlderThan             = "older-than"
 	CliAddIdentityLocalPrivateKey = "private_key"
 	CliAddIdentityLocalName       = "name"
 	CliCreateIdentityLocalName    = "name"
+	CliReplicateFromVault         = "from-vault"
+	CliReplicateToVault           = "to-vault"
+	CliReplicateFromIdentity      = "from-identity"
+	CliReplicateToIdentity        = "to-identity"
+	CliReplicatePrefix            = "prefix"
+	CliReplicateMap               = "map"
+	CliReplicateInclude           = "include"
+	CliReplicateExclude           = "exclude"
+	CliDiffLeft                   = "left"
+	CliDiffRight                  = "right"
+	CliDiffLeftIdentity           = "left-identity"
+	CliDiffRightIdentity          = "right-identity"
+	CliDiffShowValues             = "show-values"
+	CliDiffExitCode               = "exit-code"
+	CliSnapshotOut                = "out"
+	CliSnapshotAgainst            = "against"
+	CliSnapshotFormat             = "format"
+	CliRotateIdentityId           = "id"
+	CliOperatorName               = "name"
+	CliOperatorPublicKey          = "public-key"
+	CliKeyShareVault              = "vault"
+	CliKeyShareShares             = "shares"
+	CliKeyShareThreshold          = "threshold"
+	CliKeyShareFormat             = "format"
+	CliKeyShareOutDir             = "out-dir"
+	CliKeyShareIn                 = "in"
+	CliKeyShareForce              = "force"
+	CliBackupPassphrase           = "passphrase"
+	CliBackupOut                  = "out"
+	CliBackupIn                   = "in"
+	CliBackupVault                = "vault"
+	CliBackupIdentity             = "identity"
+	CliBackupConflict             = "on-conflict"
+	CliBackupPreview              = "preview"
+	CliDoctorFix                  = "fix"
+	CliDoctorCheckServer          = "check-server"
+	CliLocalIdentityVault         = "vault"
+	CliLocalIdentityName          = "name"
+	CliLocalIdentityNewName       = "new-name"
+	CliLocalIdentityFormat        = "format"
+	CliLocalIdentityPublicOnly    = "public-only"
+	CliLocalIdentityCheckServer   = "check-server"
+	CliRequestAccessName          = "name"
+	CliRequestAccessRights        = "rights"
+	CliRequestAccessNote          = "note"
+	CliRequestAccessOut           = "out"
+	CliApproveBundle              = "bundle"
+	CliApproveRemoveRight         = "remove-right"
+	CliApproveYes                 = "yes"
+	CliFingerprintPublicKey       = "public-key"
+	CliFingerprintVaultId         = "vault-id"
 
 	App = "VAULT_CLI"
 )
@@ -67,13 +161,36 @@ func cliRunner() {
 	app := &cli.App{
 		Usage:   "vault-cli",
 		Version: fmt.Sprintf("%s [%s]", version, commit),
-		Flags: []cli.Flag{
+		Flags: append([]cli.Flag{
 			&cli.StringFlag{
 				Name:    CliLogLevel,
 				EnvVars: []string{getFlagEnvByFlagName(CliLogLevel)},
 				Value:   "info",
 				Usage:   "Loglevel debug, info, warn, error",
 			},
+			&cli.StringFlag{
+				Name:    CliLogFormat,
+				EnvVars: []string{getFlagEnvByFlagName(strings.ReplaceAll(CliLogFormat, "-", "_"))},
+				Value:   logger.FormatAuto,
+				Usage:   "Log format json, console or auto which is console for a terminal",
+			},
+			&cli.StringFlag{
+				Name:    CliLogFile,
+				EnvVars: []string{getFlagEnvByFlagName(strings.ReplaceAll(CliLogFile, "-", "_"))},
+				Usage:   "Write logs to this file instead of stderr",
+			},
+			&cli.IntFlag{
+				Name:    CliLogMaxSize,
+				EnvVars: []string{getFlagEnvByFlagName(strings.ReplaceAll(CliLogMaxSize, "-", "_"))},
+				Value:   10,
+				Usage:   "Size in MB after the log file is rotated",
+			},
+			&cli.IntFlag{
+				Name:    CliLogMaxBackups,
+				EnvVars: []string{getFlagEnvByFlagName(strings.ReplaceAll(CliLogMaxBackups, "-", "_"))},
+				Value:   3,
+				Usage:   "Number of rotated log files to keep",
+			},
 			&cli.StringFlag{
 				Name:    CliServerUrl,
 				EnvVars: []string{getFlagEnvByFlagName(CliServerUrl)},
@@ -92,13 +209,26 @@ func cliRunner() {
 				Value:   "./.cryptvault/",
 				Usage:   "Path to folder where to save all created data",
 			},
-		},
+			&cli.StringFlag{
+				Name:    CliOutput,
+				EnvVars: []string{getFlagEnvByFlagName(CliOutput)},
+				Value:   OutputText,
+				Usage:   "Format of errors text or json, json errors are written to stderr as one object",
+			},
+			&cli.BoolFlag{
+				Name:    CliDryRun,
+				Aliases: []string{"dryRun"},
+				EnvVars: []string{getFlagEnvByFlagName(strings.ReplaceAll(CliDryRun, "-", "_")), getFlagEnvByFlagName("dryRun")},
+				Usage:   "Print api calls and file changes which would be made without doing them",
+			},
+		}, append(transportFlags(), traceFlags()...)...),
 		Before: runner.Before,
+		After:  runner.After,
 		Commands: []*cli.Command{
 			{
 				Name:  "local",
 				Usage: "To handle with local files",
-				Subcommands: []*cli.Command{
+				Subcommands: append([]*cli.Command{
 					{
 						Name:   "init",
 						Usage:  "create a local workspace for an already exist Vault",
@@ -126,13 +256,13 @@ func cliRunner() {
 								Name:        "identity-by-private-key",
 								Usage:       "add a identity local to the file structure. ",
 								Description: "Useful if an identity was create by some one else, but you will use it.",
-								Action:      runner.add_identity,
+								Action:      runner.audited(runner.add_identity),
 								Flags: []cli.Flag{
 									&cli.StringFlag{
 										Name:     CliAddIdentityLocalPrivateKey,
 										Aliases:  []string{"key"},
 										Required: true,
-										Usage:    "Private Key of identity",
+										Usage:    "Private Key of identity as b64, SEC1 or PKCS#8 pem or OpenSSH ECDSA key",
 									},
 									&cli.StringFlag{
 										Name:  CliAddIdentityLocalName,
@@ -150,7 +280,7 @@ func cliRunner() {
 								Name:        "identity",
 								Usage:       "create a new identity key pair without register at vault.cloud. ",
 								Description: "Useful if your identity will register by an other team so you can send them you public key.",
-								Action:      runner.create_local_identity,
+								Action:      runner.audited(runner.create_local_identity),
 								Flags: []cli.Flag{
 									&cli.StringFlag{
 										Name:     CliCreateIdentityLocalName,
@@ -163,6 +293,14 @@ func cliRunner() {
 							},
 						},
 					},
+					GetLocalKeyCommand(&runner),
+					GetLocalBackupCommand(&runner),
+					GetLocalRestoreCommand(&runner),
+					GetLocalDoctorCommand(&runner),
+					GetLocalRequestAccessCommand(&runner),
+					GetLocalTrashCommand(&runner),
+					GetLocalAuditCommand(&runner),
+					GetLocalCacheCommand(&runner),
 					{
 						Name:   "list-vault",
 						Usage:  "All local available Vaults",
@@ -185,12 +323,12 @@ func cliRunner() {
 							},
 						},
 					},
-				},
+				}, GetLocalIdentityCommands(&runner)...),
 			},
 			{
 				Name:   "create_vault",
 				Usage:  "Create a new Vault",
-				Action: runner.create_vault,
+				Action: runner.audited(runner.create_vault),
 				Flags: []cli.Flag{
 					&cli.StringFlag{
 						Name:     CliCreateVaultVaultName,
@@ -209,30 +347,22 @@ func cliRunner() {
 				},
 			},
 			GetProtectedCommand(&runner),
+			GetReplicateCommand(&runner),
+			GetFingerprintCommand(),
+			GetServeCommand(&runner),
+			GetDockerCredentialCommand(&runner),
 		},
 	}
-	if err := app.Run(os.Args); err != nil {
-		if gqlErr, ok := err.(gqlerror.List); ok {
-			for i, err := range gqlErr {
-				fmt.Printf("Error %d:\n", i+1)
-				fmt.Printf("Message: %s \n", err.Message)
-				fmt.Print("Details: \n")
-				for k, v := range err.Extensions {
-					if v == "" {
-						v = "-"
-					}
-					fmt.Printf("\t%s:  %s\n", k, v)
-				}
-			}
-		} else {
-			fmt.Println("Error:", err)
-		}
+	if err := app.Run(dockerCredentialArgs(os.Args)); err != nil {
+		os.Exit(printError(os.Stderr, err, runner.output))
 	}
 }
 
 type Runner struct {
 	api         client.ApiHandler
 	fileHandler FileHandling
+	tracer      *tracer
+	output      string
 }
 
 func (r *Runner) LocalListVault(c *cli.Context) error {
@@ -269,8 +399,27 @@ func (r *Runner) LocalSelectVault(c *cli.Context) error {
 }
 
 func (r *Runner) Before(c *cli.Context) error {
-	_, err := logger.Initialize(c.String(CliLogLevel))
+	r.output = c.String(CliOutput)
+	if r.output != OutputText && r.output != OutputJSON {
+		return withExitCode(ExitUsage, fmt.Errorf("unknown output format %s", r.output))
+	}
+	_, err := logger.InitializeWithConfig(logger.Config{
+		Level:      c.String(CliLogLevel),
+		Format:     c.String(CliLogFormat),
+		File:       c.String(CliLogFile),
+		MaxSizeMB:  c.Int(CliLogMaxSize),
+		MaxBackups: c.Int(CliLogMaxBackups),
+	})
+	if err != nil {
+		return withExitCode(ExitUsage, err)
+	}
 
+	// the api library uses http.DefaultTransport for protected calls, so it is replaced instead of passing an own client
+	r.tracer = newTracer(c)
+	http.DefaultTransport, err = newTransport(c, r.tracer)
+	if err != nil {
+		return err
+	}
 	r.api = client.NewApi(c.String(CliServerUrl), http.DefaultClient)
 	if c.Bool(CliSaveToFile) {
 		r.fileHandler = &FileHandler{
@@ -283,10 +432,18 @@ func (r *Runner) Before(c *cli.Context) error {
 	} else {
 		r.fileHandler = &FileHandlerMock{}
 	}
+	if c.Bool(CliDryRun) {
+		r.api = &dryRunVaultApi{ApiHandler: r.api}
+		r.fileHandler = &dryRunFileHandler{FileHandling: r.fileHandler}
+	}
 
 	return err
 }
 
+func (r *Runner) After(c *cli.Context) error {
+	return r.tracer.write()
+}
+
 func (r *Runner) init_vault(c *cli.Context) error {
 	vaultName := c.String(CliInitVaultName)
 	vaultID := c.String(CliInitVaultId)
@@ -337,6 +494,8 @@ func (r *Runner) create_local_identity(c *cli.Context) error {
 	}
 	fmt.Printf("New Local identity created at %s\n", r.fileHandler.FullPath(fmt.Sprintf("%s/identity/%s", vaultName, name)))
 	fmt.Printf("Your public key to share with someone who can add you to the cryptvault.cloud:\n\n%s\n", b64PubKey)
+	fmt.Printf("\nFingerprint to verify the key over an other channel: %s\n", fingerprintOrUnknown(pubKey))
+	fmt.Printf("\nOr send a signed access request created by: vault-cli local request-access --name %s --rights <rights>\n", name)
 	return nil
 
 }
@@ -344,7 +503,7 @@ func (r *Runner) create_local_identity(c *cli.Context) error {
 func (r *Runner) add_identity(c *cli.Context) error {
 	private_key_str := c.String(CliAddIdentityLocalPrivateKey)
 	name := c.String(CliAddIdentityLocalName)
-	key, err := helper.GetPrivateKeyFromB64String(private_key_str)
+	key, err := parsePrivateKey(private_key_str)
 	if err != nil {
 		return err
 	}
@@ -364,6 +523,10 @@ func (r *Runner) add_identity(c *cli.Context) error {
 	if err != nil {
 		return err
 	}
+	b64PrivKey, err := helper.GetB64FromPrivateKey(key)
+	if err != nil {
+		return err
+	}
 	if name == "" {
 		serverIdentity, err := r.api.GetProtectedApi(key, vaultId).GetIdentity(identityId)
 		if err != nil {
@@ -372,7 +535,7 @@ func (r *Runner) add_identity(c *cli.Context) error {
 		name = *serverIdentity.Name
 	}
 	err = errors.Join(r.fileHandler.SaveTextToFile(fmt.Sprintf("%s/identity/%s/key.pub", vaultName, name), string(b64PubKey)), err)
-	err = errors.Join(r.fileHandler.SaveTextToFile(fmt.Sprintf("%s/identity/%s/key", vaultName, name), private_key_str), err)
+	err = errors.Join(r.fileHandler.SaveTextToFile(fmt.Sprintf("%s/identity/%s/key", vaultName, name), b64PrivKey), err)
 	err = errors.Join(r.fileHandler.SaveTextToFile(fmt.Sprintf("%s/identity/%s/id", vaultName, name), identityId), err)
 	if err != nil {
 		return err
@@ -384,7 +547,6 @@ func (r *Runner) create_vault(c *cli.Context) error {
 	vaultName := c.String(CliCreateVaultVaultName)
 	privKey, pubKey, vaultID, err := r.api.NewVault(vaultName, c.String(CliCreateVaultVaultToken))
 	if err != nil {
-		log.Println("hier", r.api)
 		return err
 	}
 
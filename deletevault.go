@@ -0,0 +1,226 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/urfave/cli/v2"
+)
+
+// vaultInventory is everything left at a vault, except the identity used to delete it.
+// Values are only those encrypted for the acting identity, the server gives no access to others.
+type vaultInventory struct {
+	identities []*queriedIdentity
+	values     []inventoryValue
+	identityId string
+	// complete is set if the acting identity is the operator of the local workspace
+	complete bool
+}
+
+type inventoryValue struct {
+	id   string
+	name string
+}
+
+func deleteVaultFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.BoolFlag{
+			Name:  CliDeleteVaultInventory,
+			Usage: "Only list all remaining identities and values",
+		},
+		&cli.BoolFlag{
+			Name:  CliDeleteVaultPurge,
+			Usage: "Delete all values and identities before the vault",
+		},
+		&cli.StringFlag{
+			Name:  CliDeleteVaultExport,
+			Usage: "Write an encrypted export of all values and local keys to this file after the confirmation, before deleting",
+		},
+		&cli.StringFlag{
+			Name:    CliBackupPassphrase,
+			EnvVars: []string{getFlagEnvByFlagName(CliBackupPassphrase)},
+			Usage:   "Passphrase to encrypt the export, asked at the terminal if not set",
+		},
+		&cli.BoolFlag{
+			Name:  CliDeleteYes,
+			Usage: "Delete without asking",
+		},
+	}
+}
+
+func (r *ProtectedRunner) vaultInventory(c *cli.Context) (*vaultInventory, error) {
+	ownId, err := identityIdOfKey(&r.privateKey.PublicKey, *r.vaultId)
+	if err != nil {
+		return nil, err
+	}
+	identities, err := r.queryIdentities(c)
+	if err != nil {
+		return nil, err
+	}
+	inventory := &vaultInventory{identityId: ownId}
+	// only a saved operator key proves the acting key is the operator, without it the inventory may be partial
+	if localVault, err := r.runner.vaultNameById(*r.vaultId); err == nil {
+		if b64PubKey, err := r.runner.fileHandler.ReadTextFile(fmt.Sprintf("%s/operator/key.pub", localVault)); err == nil {
+			if operatorKey, err := parsePublicKey(strings.TrimSpace(b64PubKey)); err == nil {
+				operatorId, err := identityIdOfKey(operatorKey, *r.vaultId)
+				inventory.complete = err == nil && operatorId == ownId
+			}
+		}
+	}
+	for _, identity := range identities {
+		if identity.Id != ownId {
+			inventory.identities = append(inventory.identities, identity)
+		}
+	}
+	sort.Slice(inventory.identities, func(i, j int) bool {
+		return identityName(inventory.identities[i]) < identityName(inventory.identities[j])
+	})
+	related, err := r.api.GetAllRelatedValues(ownId)
+	if err != nil {
+		return nil, err
+	}
+	for _, v := range related {
+		inventory.values = append(inventory.values, inventoryValue{id: v.Id, name: v.Name})
+	}
+	sort.Slice(inventory.values, func(i, j int) bool { return inventory.values[i].name < inventory.values[j].name })
+	return inventory, nil
+}
+
+func identityName(identity *queriedIdentity) string {
+	if identity.Name == nil {
+		return ""
+	}
+	return *identity.Name
+}
+
+func (inventory *vaultInventory) print() {
+	fmt.Printf("Identities (%d):\n", len(inventory.identities))
+	for _, identity := range inventory.identities {
+		fmt.Printf("\t%s\t%s\n", identityName(identity), identity.Id)
+		for _, right := range identity.Rights {
+			fmt.Printf("\t\t%s\n", rightString(right.Right, right.RightValuePattern))
+		}
+	}
+	fmt.Printf("Values (%d):\n", len(inventory.values))
+	for _, value := range inventory.values {
+		fmt.Printf("\t%s\n", value.name)
+	}
+	if !inventory.complete {
+		fmt.Printf("Only values readable by identity %s are listed, use the operator key to see all values\n", inventory.identityId)
+	}
+}
+
+// exportVault writes all values and the local keys of the vault encrypted like a local backup.
+func (r *ProtectedRunner) exportVault(c *cli.Context, localVault string, inventory *vaultInventory) error {
+	if isDryRun(c) {
+		printDryRun("write export of %d values to %s", len(inventory.values), c.String(CliDeleteVaultExport))
+		return nil
+	}
+	passphrase, err := readPassphrase(c, true)
+	if err != nil {
+		return err
+	}
+	if len(passphrase) < 8 {
+		return fmt.Errorf("passphrase of at least 8 characters is required for --%s", CliDeleteVaultExport)
+	}
+	vault := &backupVault{Name: localVault, VaultId: *r.vaultId, Identities: map[string]map[string]string{}}
+	if localVault != "" {
+		vault, err = r.runner.backupVault(localVault, func(string) bool { return true })
+		if err != nil {
+			return err
+		}
+	}
+	for _, v := range inventory.values {
+		value, err := r.api.GetIdentityValueById(v.id)
+		if err != nil {
+			return fmt.Errorf("export of value %s failed: %w", v.name, err)
+		}
+		vault.Values = append(vault.Values, backupValue{Name: value.Name, Type: string(value.Type), Value: value.Value})
+	}
+	archive := &backupArchive{CreatedAt: time.Now().UTC(), Vaults: []backupVault{*vault}}
+	content, err := encryptBackup(passphrase, archive)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(c.String(CliDeleteVaultExport), content, 0600); err != nil {
+		return err
+	}
+	fmt.Printf("Export of %d values saved at %s\n", len(vault.Values), c.String(CliDeleteVaultExport))
+	return nil
+}
+
+func (r *ProtectedRunner) DeleteVault(c *cli.Context) error {
+	vault, err := r.api.GetVault()
+	if err != nil {
+		return err
+	}
+	inventory, err := r.vaultInventory(c)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Vault to delete:\nID: %s\nName: %s\n", vault.Id, vault.Name)
+	inventory.print()
+	if c.Bool(CliDeleteVaultInventory) {
+		return nil
+	}
+	empty := len(inventory.identities) == 0 && len(inventory.values) == 0
+	if !empty && !c.Bool(CliDeleteVaultPurge) {
+		return withExitCode(ExitConflict, fmt.Errorf("vault is not empty, use --%s to delete all values and identities first", CliDeleteVaultPurge))
+	}
+
+	localVault, err := r.runner.vaultNameById(*r.vaultId)
+	if err != nil {
+		localVault = ""
+	}
+	question := fmt.Sprintf("Delete vault %s?", vault.Name)
+	if !empty {
+		question = fmt.Sprintf("Delete %d values, %d identities and the vault %s?", len(inventory.values), len(inventory.identities), vault.Name)
+	}
+	if err := confirmDestructive(c, question); err != nil {
+		return err
+	}
+	// the export is written after the confirmation, so an aborted delete leaves no file with all values behind
+	if c.String(CliDeleteVaultExport) != "" {
+		if err := r.exportVault(c, localVault, inventory); err != nil {
+			return err
+		}
+	}
+
+	// values first, identities are still needed to have access to them
+	for i, value := range inventory.values {
+		if err := r.api.DeleteValue(value.id); err != nil {
+			return fmt.Errorf("delete value %s failed: %w", value.name, err)
+		}
+		fmt.Printf("[%d/%d] value %s deleted\n", i+1, len(inventory.values), value.name)
+	}
+	for i, identity := range inventory.identities {
+		if err := r.api.DeleteIdentity(identity.Id); err != nil {
+			return fmt.Errorf("delete identity %s failed: %w", identityName(identity), err)
+		}
+		fmt.Printf("[%d/%d] identity %s deleted\n", i+1, len(inventory.identities), identityName(identity))
+	}
+	err = r.api.DeleteVault(*r.vaultId)
+	if err != nil {
+		if !inventory.complete {
+			return fmt.Errorf("%w\nvalues not readable by identity %s were not purged, retry with the operator key", err, inventory.identityId)
+		}
+		return err
+	}
+	fmt.Println("Vault Deleted")
+
+	if localVault == "" {
+		return nil
+	}
+	trashId, err := r.runner.moveToTrash(localVault)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Local vault folder %s was moved to the trash as %s\n", localVault, trashId)
+	if selected, err := r.runner.fileHandler.SelectedVault(); err == nil && strings.TrimSpace(selected) == localVault {
+		return r.runner.fileHandler.DeleteFolder("/currentVault.txt")
+	}
+	return nil
+}
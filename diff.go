@@ -0,0 +1,170 @@
+package main
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"sort"
+	"strings"
+
+	client "github.com/cryptvault-cloud/api"
+	"github.com/urfave/cli/v2"
+)
+
+// diffSide is one side of a diff, all values below prefix readable by identityId.
+type diffSide struct {
+	spec       string
+	api        client.ProtectedApiHandler
+	identityId string
+	prefix     string
+}
+
+type diffEntry struct {
+	hash  [32]byte
+	value string
+}
+
+func GetDiffCommand(pRunner *ProtectedRunner) *cli.Command {
+	return &cli.Command{
+		Name:        "diff",
+		Usage:       "Compare values of two prefixes or two vaults",
+		Description: "A side is either a prefix like VALUES.app of the current vault or vault:<local vault name>/<prefix> to use an identity of another local vault.",
+		Action:      pRunner.Diff,
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:     CliDiffLeft,
+				Usage:    "Left side something like vault:staging/VALUES.app",
+				Required: true,
+			},
+			&cli.StringFlag{
+				Name:     CliDiffRight,
+				Usage:    "Right side something like vault:prod/VALUES.app",
+				Required: true,
+			},
+			&cli.StringFlag{
+				Name:  CliDiffLeftIdentity,
+				Usage: "Local identity used for a vault: left side, use operator for the operator key",
+				Value: OperatorIdentityName,
+			},
+			&cli.StringFlag{
+				Name:  CliDiffRightIdentity,
+				Usage: "Local identity used for a vault: right side, use operator for the operator key",
+				Value: OperatorIdentityName,
+			},
+			&cli.BoolFlag{
+				Name:  CliDiffShowValues,
+				Usage: "Print the secrets of differing values",
+			},
+			&cli.BoolFlag{
+				Name:  CliDiffExitCode,
+				Usage: "Exit with code 1 if there are differences",
+			},
+		},
+	}
+}
+
+func (r *ProtectedRunner) diffSide(spec, identityName string) (*diffSide, error) {
+	rest, isVault := strings.CutPrefix(spec, "vault:")
+	if !isVault {
+		identityId, err := identityIdOfKey(&r.privateKey.PublicKey, *r.vaultId)
+		if err != nil {
+			return nil, err
+		}
+		return &diffSide{spec: spec, api: r.api, identityId: identityId, prefix: spec}, nil
+	}
+	vaultName, prefix, found := strings.Cut(rest, "/")
+	if !found {
+		return nil, fmt.Errorf("invalid side %s, expected vault:<name>/<prefix>", spec)
+	}
+	api, _, identityId, err := r.runner.protectedApiByWorkspace(vaultName, identityName)
+	if err != nil {
+		return nil, err
+	}
+	return &diffSide{spec: spec, api: api, identityId: identityId, prefix: prefix}, nil
+}
+
+// values returns all readable values below the prefix by their name relative to the prefix.
+func (s *diffSide) values() (map[string]diffEntry, error) {
+	if !ValueNameRegex.MatchString(s.prefix) {
+		return nil, fmt.Errorf("invalid prefix %s at %s", s.prefix, s.spec)
+	}
+	related, err := s.api.GetAllRelatedValues(s.identityId)
+	if err != nil {
+		return nil, err
+	}
+	result := make(map[string]diffEntry)
+	for _, v := range related {
+		key, found := strings.CutPrefix(v.Name, s.prefix+".")
+		if !found {
+			continue
+		}
+		value, err := s.api.GetIdentityValueById(v.Id)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", v.Name, err)
+		}
+		result[key] = diffEntry{
+			hash:  sha256.Sum256([]byte(fmt.Sprintf("%s:%s", value.Type, value.Value))),
+			value: value.Value,
+		}
+	}
+	return result, nil
+}
+
+func (r *ProtectedRunner) Diff(c *cli.Context) error {
+	showValues := c.Bool(CliDiffShowValues)
+	left, err := r.diffSide(c.String(CliDiffLeft), c.String(CliDiffLeftIdentity))
+	if err != nil {
+		return err
+	}
+	right, err := r.diffSide(c.String(CliDiffRight), c.String(CliDiffRightIdentity))
+	if err != nil {
+		return err
+	}
+	leftValues, err := left.values()
+	if err != nil {
+		return err
+	}
+	rightValues, err := right.values()
+	if err != nil {
+		return err
+	}
+
+	onlyLeft, onlyRight, different := make([]string, 0), make([]string, 0), make([]string, 0)
+	for k, l := range leftValues {
+		rv, ok := rightValues[k]
+		if !ok {
+			onlyLeft = append(onlyLeft, k)
+		} else if rv.hash != l.hash {
+			different = append(different, k)
+		}
+	}
+	for k := range rightValues {
+		if _, ok := leftValues[k]; !ok {
+			onlyRight = append(onlyRight, k)
+		}
+	}
+	sort.Strings(onlyLeft)
+	sort.Strings(onlyRight)
+	sort.Strings(different)
+
+	fmt.Printf("Only at %s:\n", left.spec)
+	for _, k := range onlyLeft {
+		fmt.Printf("\t%s\n", k)
+	}
+	fmt.Printf("Only at %s:\n", right.spec)
+	for _, k := range onlyRight {
+		fmt.Printf("\t%s\n", k)
+	}
+	fmt.Print("Different content:\n")
+	for _, k := range different {
+		fmt.Printf("\t%s\n", k)
+		if showValues {
+			fmt.Printf("\t\t< %s\n\t\t> %s\n", leftValues[k].value, rightValues[k].value)
+		}
+	}
+	fmt.Printf("%d only left, %d only right, %d different\n", len(onlyLeft), len(onlyRight), len(different))
+
+	if c.Bool(CliDiffExitCode) && len(onlyLeft)+len(onlyRight)+len(different) > 0 {
+		return silentExit(ExitError)
+	}
+	return nil
+}
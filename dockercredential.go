@@ -0,0 +1,225 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	client "github.com/cryptvault-cloud/api"
+	"github.com/cryptvault-cloud/vault-cli/logger"
+	"github.com/urfave/cli/v2"
+)
+
+// DockerCredentialHelperName is the binary name docker looks for with "credsStore": "cryptvault".
+const DockerCredentialHelperName = "docker-credential-cryptvault"
+
+// errCredentialsNotFound has to be exactly this message, docker treats it as missing credentials.
+var errCredentialsNotFound = errors.New("credentials not found in native keychain")
+
+// dockerCredentials is the json of the credential helper protocol.
+type dockerCredentials struct {
+	ServerURL string `json:"ServerURL"`
+	Username  string `json:"Username"`
+	Secret    string `json:"Secret"`
+}
+
+// registryValue is the content of a registry value.
+type registryValue struct {
+	ServerURL string `json:"serverURL"`
+	Username  string `json:"username"`
+	Secret    string `json:"secret"`
+}
+
+// dockerCredentialArgs maps a call as docker-credential-cryptvault <action> to the docker-credential command.
+func dockerCredentialArgs(args []string) []string {
+	if len(args) == 0 || filepath.Base(args[0]) != DockerCredentialHelperName {
+		return args
+	}
+	return append([]string{args[0], "docker-credential"}, args[1:]...)
+}
+
+func GetDockerCredentialCommand(runner *Runner) *cli.Command {
+	pRunner := &ProtectedRunner{runner: runner}
+	return &cli.Command{
+		Name:        "docker-credential",
+		Usage:       "Docker credential helper, also used if called as " + DockerCredentialHelperName,
+		Description: "Credentials of a registry host are stored as JSON value {serverURL, username, secret} at <prefix>.<host>, a port is separated by _ instead of :.",
+		Before:      pRunner.Before,
+		Flags: append(protectedFlags(),
+			&cli.StringFlag{
+				Name:    CliDockerPrefix,
+				EnvVars: []string{getFlagEnvByFlagName("docker_" + CliDockerPrefix)},
+				Usage:   "Prefix of the registry values",
+				Value:   "VALUES.registry",
+			},
+		),
+		Subcommands: []*cli.Command{
+			{
+				Name:   "get",
+				Usage:  "Print the credentials of the server url read from stdin",
+				Action: pRunner.dockerCredentialAction(pRunner.DockerCredentialGet),
+			},
+			{
+				Name:   "store",
+				Usage:  "Store the credentials read as json from stdin",
+				Action: pRunner.audited(pRunner.dockerCredentialAction(pRunner.DockerCredentialStore)),
+			},
+			{
+				Name:   "erase",
+				Usage:  "Delete the credentials of the server url read from stdin",
+				Action: pRunner.audited(pRunner.dockerCredentialAction(pRunner.DockerCredentialErase)),
+			},
+			{
+				Name:   "list",
+				Usage:  "Print all server urls with their username",
+				Action: pRunner.dockerCredentialAction(pRunner.DockerCredentialList),
+			},
+		},
+	}
+}
+
+// dockerCredentialAction writes errors to stdout and exits with 1 as the protocol expects.
+func (r *ProtectedRunner) dockerCredentialAction(action func(c *cli.Context, in io.Reader, out io.Writer) error) cli.ActionFunc {
+	return func(c *cli.Context) error {
+		if err := action(c, os.Stdin, os.Stdout); err != nil {
+			logger.GetWithStructAndFunc("ProtectedRunner", "dockerCredentialAction").Debugw("credential helper failed", "command", c.Command.Name, "error", err)
+			fmt.Fprintln(os.Stdout, logger.RedactString(err.Error()))
+			return silentExit(ExitError)
+		}
+		return nil
+	}
+}
+
+// registryValueName returns the value name of the registry host of serverURL.
+func registryValueName(prefix, serverURL string) (string, error) {
+	serverURL = strings.TrimSpace(serverURL)
+	if !strings.Contains(serverURL, "://") {
+		serverURL = "https://" + serverURL
+	}
+	u, err := url.Parse(serverURL)
+	if err != nil || u.Host == "" {
+		return "", fmt.Errorf("invalid server url %s", serverURL)
+	}
+	name := fmt.Sprintf("%s.%s", strings.TrimSuffix(prefix, "."), strings.ReplaceAll(strings.ToLower(u.Host), ":", "_"))
+	if !ValueNameRegex.MatchString(name) {
+		return "", fmt.Errorf("server url %s can not be mapped to a value name, got %s", serverURL, name)
+	}
+	return name, nil
+}
+
+func isValueNotFound(err error) bool {
+	return exitCode(err) == ExitNotFound || strings.Contains(strings.ToLower(err.Error()), "not found")
+}
+
+func readServerURL(in io.Reader) (string, error) {
+	content, err := io.ReadAll(in)
+	if err != nil {
+		return "", err
+	}
+	serverURL := strings.TrimSpace(string(content))
+	if serverURL == "" {
+		return "", errors.New("no server url on stdin")
+	}
+	return serverURL, nil
+}
+
+func (r *ProtectedRunner) DockerCredentialGet(c *cli.Context, in io.Reader, out io.Writer) error {
+	serverURL, err := readServerURL(in)
+	if err != nil {
+		return err
+	}
+	name, err := registryValueName(c.String(CliDockerPrefix), serverURL)
+	if err != nil {
+		return err
+	}
+	value, err := r.fetchValue(name)
+	if err != nil {
+		if isValueNotFound(err) {
+			return errCredentialsNotFound
+		}
+		return err
+	}
+	registry := &registryValue{}
+	if err := json.Unmarshal([]byte(value), registry); err != nil {
+		return fmt.Errorf("value %s is no registry json: %w", name, err)
+	}
+	return json.NewEncoder(out).Encode(&dockerCredentials{ServerURL: serverURL, Username: registry.Username, Secret: registry.Secret})
+}
+
+func (r *ProtectedRunner) DockerCredentialStore(c *cli.Context, in io.Reader, out io.Writer) error {
+	creds := &dockerCredentials{}
+	if err := json.NewDecoder(in).Decode(creds); err != nil {
+		return fmt.Errorf("invalid credentials json: %w", err)
+	}
+	name, err := registryValueName(c.String(CliDockerPrefix), creds.ServerURL)
+	if err != nil {
+		return err
+	}
+	content, err := json.Marshal(&registryValue{ServerURL: creds.ServerURL, Username: creds.Username, Secret: creds.Secret})
+	if err != nil {
+		return err
+	}
+	existing, err := r.api.GetValueByName(name)
+	if err != nil {
+		if !isValueNotFound(err) {
+			return err
+		}
+		_, err = r.api.AddValue(name, string(content), client.ValueTypeJson)
+		return err
+	}
+	_, err = r.api.UpdateValue(existing.Id, name, string(content), client.ValueTypeJson)
+	return err
+}
+
+func (r *ProtectedRunner) DockerCredentialErase(c *cli.Context, in io.Reader, out io.Writer) error {
+	serverURL, err := readServerURL(in)
+	if err != nil {
+		return err
+	}
+	name, err := registryValueName(c.String(CliDockerPrefix), serverURL)
+	if err != nil {
+		return err
+	}
+	value, err := r.api.GetValueByName(name)
+	if err != nil {
+		if isValueNotFound(err) {
+			return errCredentialsNotFound
+		}
+		return err
+	}
+	return r.api.DeleteValue(value.Id)
+}
+
+func (r *ProtectedRunner) DockerCredentialList(c *cli.Context, in io.Reader, out io.Writer) error {
+	prefix := strings.TrimSuffix(c.String(CliDockerPrefix), ".") + "."
+	identityId, err := identityIdOfKey(&r.privateKey.PublicKey, *r.vaultId)
+	if err != nil {
+		return err
+	}
+	related, err := r.api.GetAllRelatedValues(identityId)
+	if err != nil {
+		return err
+	}
+	result := make(map[string]string)
+	for _, v := range related {
+		if !strings.HasPrefix(v.Name, prefix) {
+			continue
+		}
+		value, err := r.api.GetIdentityValueById(v.Id)
+		if err != nil {
+			return fmt.Errorf("%s: %w", v.Name, err)
+		}
+		registry := &registryValue{}
+		if err := json.Unmarshal([]byte(value.Value), registry); err != nil || registry.ServerURL == "" {
+			// not written by store, nothing docker could use
+			continue
+		}
+		result[registry.ServerURL] = registry.Username
+	}
+	return json.NewEncoder(out).Encode(result)
+}
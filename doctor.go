@@ -0,0 +1,218 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/cryptvault-cloud/helper"
+	"github.com/urfave/cli/v2"
+)
+
+const (
+	workspaceFileMode   fs.FileMode = 0600
+	workspaceFolderMode fs.FileMode = 0700
+)
+
+// doctor collects the findings of one workspace check.
+type doctor struct {
+	runner      *Runner
+	fix         bool
+	checkServer bool
+	dryRun      bool
+	problems    int
+	fixed       int
+}
+
+func GetLocalDoctorCommand(runner *Runner) *cli.Command {
+	return &cli.Command{
+		Name:        "doctor",
+		Usage:       "Check the local workspace for broken or insecure files",
+		Description: "Validates vault ids, keys, identity ids and file permissions of every local vault.",
+		Action: func(c *cli.Context) error {
+			// only repairs change the workspace
+			if c.Bool(CliDoctorFix) {
+				return runner.audited(runner.LocalDoctor)(c)
+			}
+			return runner.LocalDoctor(c)
+		},
+		Flags: []cli.Flag{
+			&cli.BoolFlag{
+				Name:  CliDoctorFix,
+				Usage: "Repair permissions, identity ids, public keys and the selected vault",
+			},
+			&cli.BoolFlag{
+				Name:  CliDoctorCheckServer,
+				Usage: "Check if every local identity is still registered at the server",
+			},
+		},
+	}
+}
+
+func (d *doctor) ok(subject, msg string) {
+	fmt.Printf("OK    %-40s %s\n", subject, msg)
+}
+
+// problem reports a finding, if fix is set and repair is given it will be tried to repair it.
+func (d *doctor) problem(subject, msg string, repair func() error) {
+	if d.fix && repair != nil {
+		if err := repair(); err != nil {
+			fmt.Printf("FAIL  %-40s %s, repair failed: %s\n", subject, msg, err)
+			d.problems++
+			return
+		}
+		fmt.Printf("FIXED %-40s %s\n", subject, msg)
+		d.fixed++
+		return
+	}
+	hint := ""
+	if repair != nil {
+		hint = " (fixable with --fix)"
+	}
+	fmt.Printf("FAIL  %-40s %s%s\n", subject, msg, hint)
+	d.problems++
+}
+
+func (r *Runner) LocalDoctor(c *cli.Context) error {
+	handler := r.fileHandler
+	dryRunHandler, dryRun := handler.(*dryRunFileHandler)
+	if dryRun {
+		handler = dryRunHandler.FileHandling
+	}
+	fileHandler, ok := handler.(*FileHandler)
+	if !ok {
+		return fmt.Errorf("local workspace is disabled by --%s", CliSaveToFile)
+	}
+	d := &doctor{runner: r, fix: c.Bool(CliDoctorFix), checkServer: c.Bool(CliDoctorCheckServer), dryRun: dryRun}
+
+	d.checkPermissions(fileHandler.RootPath)
+	vaults, err := r.fileHandler.AvailableVaults()
+	if err != nil {
+		return err
+	}
+	current, err := r.fileHandler.SelectedVault()
+	if err == nil {
+		current = strings.TrimSpace(current)
+		if helper.Includes(vaults, func(v string) bool { return v == current }) {
+			d.ok("currentVault.txt", fmt.Sprintf("selected vault is %s", current))
+		} else {
+			d.problem("currentVault.txt", fmt.Sprintf("selected vault %s does not exist", current), func() error {
+				return r.fileHandler.DeleteFolder("/currentVault.txt")
+			})
+		}
+	}
+
+	for _, vaultName := range vaults {
+		d.checkVault(vaultName)
+	}
+
+	fmt.Printf("%d problems found, %d fixed\n", d.problems+d.fixed, d.fixed)
+	if d.problems > 0 {
+		return silentExit(ExitError)
+	}
+	return nil
+}
+
+// checkPermissions ensures no file or folder of the workspace is accessible by others.
+func (d *doctor) checkPermissions(root string) {
+	err := filepath.WalkDir(root, func(p string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		expected := workspaceFileMode
+		if entry.IsDir() {
+			expected = workspaceFolderMode
+		}
+		if info.Mode().Perm()&^expected != 0 {
+			d.problem(p, fmt.Sprintf("permissions are %#o expected %#o", info.Mode().Perm(), expected), func() error {
+				// permissions are changed without the file handler, so dry-run has to be handled here
+				if d.dryRun {
+					printDryRun("chmod %#o %s", expected, p)
+					return nil
+				}
+				return os.Chmod(p, expected)
+			})
+		}
+		return nil
+	})
+	if err != nil {
+		d.problem(root, err.Error(), nil)
+	}
+}
+
+func (d *doctor) checkVault(vaultName string) {
+	vaultId, err := d.runner.vaultIdByName(vaultName)
+	if err != nil || vaultId == "" {
+		d.problem(vaultName, "vaultId is missing", nil)
+		return
+	}
+	d.ok(vaultName, fmt.Sprintf("vault id %s", vaultId))
+
+	if _, err := d.runner.fileHandler.ReadTextFile(fmt.Sprintf("%s/key", identityFolder(vaultName, OperatorIdentityName))); err == nil {
+		d.checkIdentity(vaultName, vaultId, OperatorIdentityName)
+	}
+	identities, err := d.runner.localIdentities(vaultName)
+	if err != nil {
+		d.problem(vaultName, err.Error(), nil)
+		return
+	}
+	for _, name := range identities {
+		d.checkIdentity(vaultName, vaultId, name)
+	}
+}
+
+func (d *doctor) checkIdentity(vaultName, vaultId, name string) {
+	folder := identityFolder(vaultName, name)
+	key, err := d.runner.identityKey(vaultName, name)
+	if err != nil {
+		d.problem(folder, fmt.Sprintf("private key is not readable: %s", err), nil)
+		return
+	}
+	b64PubKey, err := helper.GetB64FromPublicKey(&key.PublicKey)
+	if err != nil {
+		d.problem(folder, err.Error(), nil)
+		return
+	}
+	pubKeyPath := fmt.Sprintf("%s/key.pub", folder)
+	savedPubKey, err := d.runner.fileHandler.ReadTextFile(pubKeyPath)
+	if err != nil || strings.TrimSpace(savedPubKey) != b64PubKey {
+		d.problem(pubKeyPath, "public key does not match private key", func() error {
+			return d.runner.fileHandler.SaveTextToFile(pubKeyPath, b64PubKey)
+		})
+	}
+	identityId, err := identityIdOfKey(&key.PublicKey, vaultId)
+	if err != nil {
+		d.problem(folder, err.Error(), nil)
+		return
+	}
+	idPath := fmt.Sprintf("%s/id", folder)
+	savedId, err := d.runner.fileHandler.ReadTextFile(idPath)
+	if name != OperatorIdentityName || err == nil {
+		if err != nil || strings.TrimSpace(savedId) != identityId {
+			d.problem(idPath, fmt.Sprintf("id does not match key, expected %s", identityId), func() error {
+				return d.runner.fileHandler.SaveTextToFile(idPath, identityId)
+			})
+		}
+	}
+	if d.checkServer {
+		d.checkIdentityAtServer(folder, key, vaultId, identityId)
+		return
+	}
+	d.ok(folder, fmt.Sprintf("identity %s", identityId))
+}
+
+func (d *doctor) checkIdentityAtServer(folder string, key *ecdsa.PrivateKey, vaultId, identityId string) {
+	identity, err := d.runner.api.GetProtectedApi(key, vaultId).GetIdentity(identityId)
+	if err != nil || identity == nil {
+		d.problem(folder, fmt.Sprintf("identity %s is not registered at the server: %v", identityId, err), nil)
+		return
+	}
+	d.ok(folder, fmt.Sprintf("identity %s is registered at the server", identityId))
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"fmt"
+	"strings"
+
+	client "github.com/cryptvault-cloud/api"
+	"github.com/cryptvault-cloud/helper"
+	"github.com/urfave/cli/v2"
+)
+
+const dryRunId = "dry-run"
+
+// isDryRun reports whether --dry-run is set globally or at one of the commands,
+// a command flag with the same name hides the global flag from c.Bool.
+func isDryRun(c *cli.Context) bool {
+	for _, ctx := range c.Lineage() {
+		if ctx.Bool(CliDryRun) {
+			return true
+		}
+	}
+	return false
+}
+
+func printDryRun(format string, a ...any) {
+	fmt.Printf("[dry-run] "+format+"\n", a...)
+}
+
+// dryRunVaultApi prints vault creations and hands out protected apis which only print mutating calls.
+type dryRunVaultApi struct {
+	client.ApiHandler
+}
+
+func (d *dryRunVaultApi) NewVaultByPublicKey(name, token string, publicKey *ecdsa.PublicKey) (string, error) {
+	printDryRun("NewVault name=%s", name)
+	return dryRunId, nil
+}
+
+func (d *dryRunVaultApi) NewVault(name, token string) (*ecdsa.PrivateKey, *ecdsa.PublicKey, string, error) {
+	privKey, pubKey, err := d.GetNewIdentityKeyPair()
+	if err != nil {
+		return nil, nil, "", err
+	}
+	printDryRun("NewVault name=%s", name)
+	return privKey, pubKey, dryRunId, nil
+}
+
+func (d *dryRunVaultApi) GetProtectedApi(authKey *ecdsa.PrivateKey, vaultId string) client.ProtectedApiHandler {
+	return &dryRunApi{ProtectedApiHandler: d.ApiHandler.GetProtectedApi(authKey, vaultId), vaultId: vaultId}
+}
+
+// dryRunApi prints all mutating calls instead of sending them, queries are passed through.
+// UpdateVault is not wrapped, its result type is not exported and it is not used by the cli.
+type dryRunApi struct {
+	client.ProtectedApiHandler
+	vaultId string
+}
+
+func (d *dryRunApi) AddIdentity(name string, publicKey *ecdsa.PublicKey, rights []*client.RightInput) (*client.AddIdentityResponse, error) {
+	identityId, err := identityIdOfKey(publicKey, d.vaultId)
+	if err != nil {
+		return nil, err
+	}
+	printDryRun("AddIdentity name=%s id=%s rights=%s", name, identityId, rightInputsString(rights))
+	return &client.AddIdentityResponse{IdentityId: identityId, PublicKey: publicKey}, nil
+}
+
+func (d *dryRunApi) UpdateIdentity(id string, name string, rights []*client.RightInput) (*client.AddIdentityResponse, error) {
+	printDryRun("UpdateIdentity id=%s name=%s rights=%s", id, name, rightInputsString(rights))
+	return &client.AddIdentityResponse{IdentityId: id}, nil
+}
+
+func (d *dryRunApi) CreateIdentity(name string, rights []*client.RightInput) (*client.CreateIdentityResponse, error) {
+	privKey, pubKey, err := helper.GenerateNewKeyPair()
+	if err != nil {
+		return nil, err
+	}
+	res, err := d.AddIdentity(name, pubKey, rights)
+	if err != nil {
+		return nil, err
+	}
+	return &client.CreateIdentityResponse{AddIdentityResponse: res, PrivateKey: privKey}, nil
+}
+
+func (d *dryRunApi) DeleteIdentity(id string) error {
+	printDryRun("DeleteIdentity id=%s", id)
+	return nil
+}
+
+func (d *dryRunApi) DeleteIdentityValue(id *string) (int, error) {
+	printDryRun("DeleteIdentityValue id=%s", *id)
+	return 0, nil
+}
+
+func (d *dryRunApi) AddValue(key, value string, valueType client.ValueType) (string, error) {
+	printDryRun("AddValue name=%s type=%s", key, valueType)
+	return dryRunId, nil
+}
+
+func (d *dryRunApi) UpdateValue(id, key, value string, valueType client.ValueType) (string, error) {
+	printDryRun("UpdateValue id=%s name=%s type=%s", id, key, valueType)
+	return id, nil
+}
+
+func (d *dryRunApi) DeleteValue(id string) error {
+	printDryRun("DeleteValue id=%s", id)
+	return nil
+}
+
+func (d *dryRunApi) SyncValues(identityId string) error {
+	printDryRun("SyncValues identity=%s", identityId)
+	return nil
+}
+
+func (d *dryRunApi) SyncValue(id string) error {
+	printDryRun("SyncValue id=%s", id)
+	return nil
+}
+
+func (d *dryRunApi) AddIdentityValue(input client.IdentityValueInput) (string, error) {
+	printDryRun("AddIdentityValue value=%s identity=%s", input.ValueID, input.IdentityID)
+	return dryRunId, nil
+}
+
+func (d *dryRunApi) DeleteRight(rightId, identityId string) (int, error) {
+	printDryRun("DeleteRight id=%s identity=%s", rightId, identityId)
+	return 0, nil
+}
+
+func (d *dryRunApi) AddRights(rights []*client.RightInput, identityId string) ([]string, error) {
+	printDryRun("AddRights identity=%s rights=%s", identityId, rightInputsString(rights))
+	return []string{}, nil
+}
+
+func (d *dryRunApi) DeleteVault(id string) error {
+	printDryRun("DeleteVault id=%s", id)
+	return nil
+}
+
+func rightInputsString(rights []*client.RightInput) string {
+	rightStrs := make([]string, len(rights))
+	for i, right := range rights {
+		rightStrs[i] = rightString(right.Right, right.RightValuePattern)
+	}
+	return strings.Join(rightStrs, ",")
+}
+
+// dryRunFileHandler prints all writes to the workspace instead of doing them.
+type dryRunFileHandler struct {
+	FileHandling
+}
+
+func (d *dryRunFileHandler) SaveTextToFile(filePath string, content string) error {
+	printDryRun("write %s", d.FullPath(filePath))
+	return nil
+}
+
+func (d *dryRunFileHandler) DeleteFolder(filePath string) error {
+	printDryRun("delete %s", d.FullPath(filePath))
+	return nil
+}
+
+func (d *dryRunFileHandler) AppendTextToFile(filePath string, content string) error {
+	printDryRun("append %s", d.FullPath(filePath))
+	return nil
+}
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/cryptvault-cloud/vault-cli/logger"
+	"github.com/urfave/cli/v2"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+)
+
+const (
+	OutputText = "text"
+	OutputJSON = "json"
+)
+
+// Exit codes of vault-cli, 1 is used for all errors without a class and for found differences of diff and snapshot check.
+const (
+	ExitError     = 1
+	ExitUsage     = 2
+	ExitAuth      = 3
+	ExitNotFound  = 4
+	ExitConflict  = 5
+	ExitNetwork   = 6
+	ExitWorkspace = 7
+)
+
+var exitClassNames = map[int]string{
+	ExitError:     "error",
+	ExitUsage:     "usage",
+	ExitAuth:      "auth",
+	ExitNotFound:  "not_found",
+	ExitConflict:  "conflict",
+	ExitNetwork:   "network",
+	ExitWorkspace: "workspace",
+}
+
+// classifiedError is an error where the command already knows the exit code.
+type classifiedError struct {
+	code int
+	err  error
+}
+
+func (e *classifiedError) Error() string { return e.err.Error() }
+func (e *classifiedError) Unwrap() error { return e.err }
+
+func withExitCode(code int, err error) error {
+	return &classifiedError{code: code, err: err}
+}
+
+// errSilent marks an exit where the command already printed its result, f.e. found differences.
+var errSilent = errors.New("")
+
+// silentExit ends the command with code without printing an error. Unlike cli.Exit the exit happens
+// after the After functions of the app ran, so trace files are still written.
+func silentExit(code int) error {
+	return withExitCode(code, errSilent)
+}
+
+var httpStatusRegex = regexp.MustCompile(`^returned error (\d{3})`)
+
+// exitCode maps an error to one of the exit codes.
+func exitCode(err error) int {
+	var classified *classifiedError
+	if errors.As(err, &classified) {
+		return classified.code
+	}
+	var exitCoder cli.ExitCoder
+	if errors.As(err, &exitCoder) {
+		return exitCoder.ExitCode()
+	}
+	var gqlErrs gqlerror.List
+	if errors.As(err, &gqlErrs) {
+		for _, gqlErr := range gqlErrs {
+			if code := gqlExitCode(gqlErr); code != ExitError {
+				return code
+			}
+		}
+		return ExitError
+	}
+	if match := httpStatusRegex.FindStringSubmatch(err.Error()); match != nil {
+		status, _ := strconv.Atoi(match[1])
+		return httpExitCode(status)
+	}
+	// a missing or unreadable local file is a workspace error even if it is wrapped by a network error
+	if errors.Is(err, fs.ErrNotExist) || errors.Is(err, fs.ErrPermission) {
+		return ExitWorkspace
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return ExitNetwork
+	}
+	message := err.Error()
+	for _, usage := range []string{"flag provided but not defined", "Required flag", "invalid value", "flag needs an argument"} {
+		if strings.Contains(message, usage) {
+			return ExitUsage
+		}
+	}
+	return ExitError
+}
+
+func httpExitCode(status int) int {
+	switch {
+	case status == 401 || status == 403:
+		return ExitAuth
+	case status == 404:
+		return ExitNotFound
+	case status == 409:
+		return ExitConflict
+	case status == 400 || status == 422:
+		return ExitUsage
+	case status >= 500:
+		return ExitNetwork
+	}
+	return ExitError
+}
+
+// gqlExitCode maps the code extension of a GraphQL error, the message is used if there is no code.
+func gqlExitCode(gqlErr *gqlerror.Error) int {
+	text := strings.ToLower(gqlErr.Message)
+	if code, ok := gqlErr.Extensions["code"]; ok {
+		text = strings.ToLower(fmt.Sprint(code))
+	}
+	switch {
+	case containsAny(text, "unauthenticated", "unauthorized", "forbidden", "permission", "not allowed", "access denied", "jwt", "token"):
+		return ExitAuth
+	case containsAny(text, "not_found", "not found", "notfound", "no rows"):
+		return ExitNotFound
+	case containsAny(text, "conflict", "already exist", "duplicate", "unique"):
+		return ExitConflict
+	case containsAny(text, "validation", "parse_failed", "bad_user_input", "invalid"):
+		return ExitUsage
+	}
+	return ExitError
+}
+
+func containsAny(text string, parts ...string) bool {
+	for _, part := range parts {
+		if strings.Contains(text, part) {
+			return true
+		}
+	}
+	return false
+}
+
+type jsonErrorDetail struct {
+	Message    string         `json:"message"`
+	Path       string         `json:"path,omitempty"`
+	Extensions map[string]any `json:"extensions,omitempty"`
+}
+
+type jsonError struct {
+	Error struct {
+		Class   string            `json:"class"`
+		Code    int               `json:"code"`
+		Message string            `json:"message"`
+		Details []jsonErrorDetail `json:"details,omitempty"`
+	} `json:"error"`
+}
+
+// printError writes err in the requested output format and returns the exit code.
+func printError(w io.Writer, err error, output string) int {
+	code := exitCode(err)
+	if errors.Is(err, errSilent) {
+		return code
+	}
+	var gqlErrs gqlerror.List
+	isGql := errors.As(err, &gqlErrs)
+
+	if output == OutputJSON {
+		out := jsonError{}
+		out.Error.Class = exitClassNames[code]
+		out.Error.Code = code
+		out.Error.Message = logger.RedactString(err.Error())
+		if isGql {
+			for _, gqlErr := range gqlErrs {
+				out.Error.Details = append(out.Error.Details, jsonErrorDetail{Message: logger.RedactString(gqlErr.Message), Path: gqlErr.Path.String(), Extensions: gqlErr.Extensions})
+			}
+		}
+		content, _ := json.Marshal(out)
+		fmt.Fprintln(w, string(content))
+		return code
+	}
+
+	if isGql {
+		for i, err := range gqlErrs {
+			fmt.Fprintf(w, "Error %d:\n", i+1)
+			fmt.Fprintf(w, "Message: %s \n", logger.RedactString(err.Message))
+			fmt.Fprint(w, "Details: \n")
+			for k, v := range err.Extensions {
+				if v == "" {
+					v = "-"
+				}
+				fmt.Fprintf(w, "\t%s:  %s\n", k, v)
+			}
+		}
+	} else {
+		fmt.Fprintln(w, "Error:", logger.RedactString(err.Error()))
+	}
+	return code
+}
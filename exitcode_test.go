@@ -0,0 +1,92 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"net/url"
+	"testing"
+
+	"github.com/urfave/cli/v2"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+)
+
+func gqlErr(message string, code any) *gqlerror.Error {
+	err := &gqlerror.Error{Message: message}
+	if code != nil {
+		err.Extensions = map[string]any{"code": code}
+	}
+	return err
+}
+
+func TestGqlExitCode(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      *gqlerror.Error
+		expected int
+	}{
+		{name: "code unauthenticated", err: gqlErr("something failed", "UNAUTHENTICATED"), expected: ExitAuth},
+		{name: "code forbidden", err: gqlErr("something failed", "FORBIDDEN"), expected: ExitAuth},
+		{name: "code not found", err: gqlErr("something failed", "NOT_FOUND"), expected: ExitNotFound},
+		{name: "code conflict", err: gqlErr("something failed", "CONFLICT"), expected: ExitConflict},
+		{name: "code validation", err: gqlErr("something failed", "GRAPHQL_VALIDATION_FAILED"), expected: ExitUsage},
+		{name: "code bad user input", err: gqlErr("something failed", "BAD_USER_INPUT"), expected: ExitUsage},
+		{name: "code wins over message", err: gqlErr("value not found", "FORBIDDEN"), expected: ExitAuth},
+		{name: "unknown code", err: gqlErr("value not found", "INTERNAL"), expected: ExitError},
+		{name: "message jwt", err: gqlErr("jwt is expired", nil), expected: ExitAuth},
+		{name: "message invalid token is auth", err: gqlErr("invalid token", nil), expected: ExitAuth},
+		{name: "message not allowed", err: gqlErr("Not allowed to read VALUES.a", nil), expected: ExitAuth},
+		{name: "message not found", err: gqlErr("identity not found", nil), expected: ExitNotFound},
+		{name: "message no rows", err: gqlErr("sql: no rows in result set", nil), expected: ExitNotFound},
+		{name: "message already exists", err: gqlErr("value already exists", nil), expected: ExitConflict},
+		{name: "message unique", err: gqlErr("violates unique constraint", nil), expected: ExitConflict},
+		{name: "message invalid", err: gqlErr("invalid value name", nil), expected: ExitUsage},
+		{name: "message unknown", err: gqlErr("something broke", nil), expected: ExitError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := gqlExitCode(tt.err); got != tt.expected {
+				t.Fatalf("got %d, expected %d", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestExitCode(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		expected int
+	}{
+		{name: "plain error", err: errors.New("failed"), expected: ExitError},
+		{name: "classified", err: withExitCode(ExitConflict, errors.New("exists")), expected: ExitConflict},
+		{name: "wrapped classified", err: fmt.Errorf("wrapped: %w", withExitCode(ExitUsage, errors.New("bad"))), expected: ExitUsage},
+		{name: "silent", err: silentExit(ExitError), expected: ExitError},
+		{name: "cli exit", err: cli.Exit("", 3), expected: 3},
+		{name: "gql list first classified error", err: gqlerror.List{gqlErr("something broke", nil), gqlErr("identity not found", nil)}, expected: ExitNotFound},
+		{name: "gql list unknown", err: gqlerror.List{gqlErr("something broke", nil)}, expected: ExitError},
+		{name: "wrapped gql list", err: fmt.Errorf("query failed: %w", gqlerror.List{gqlErr("x", "UNAUTHENTICATED")}), expected: ExitAuth},
+		{name: "http 401", err: errors.New("returned error 401 Unauthorized: "), expected: ExitAuth},
+		{name: "http 403", err: errors.New("returned error 403 Forbidden: "), expected: ExitAuth},
+		{name: "http 404", err: errors.New("returned error 404 Not Found: "), expected: ExitNotFound},
+		{name: "http 409", err: errors.New("returned error 409 Conflict: "), expected: ExitConflict},
+		{name: "http 422", err: errors.New("returned error 422 Unprocessable Entity: "), expected: ExitUsage},
+		{name: "http 503", err: errors.New("returned error 503 Service Unavailable: "), expected: ExitNetwork},
+		{name: "http 418", err: errors.New("returned error 418 I'm a teapot: "), expected: ExitError},
+		{name: "status not at start", err: errors.New("value returned error 404"), expected: ExitError},
+		{name: "missing file", err: &fs.PathError{Op: "open", Path: ".cryptvault/currentVault.txt", Err: fs.ErrNotExist}, expected: ExitWorkspace},
+		{name: "unreadable file", err: &fs.PathError{Op: "open", Path: "key", Err: fs.ErrPermission}, expected: ExitWorkspace},
+		{name: "dial error", err: &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}, expected: ExitNetwork},
+		{name: "url error", err: &url.Error{Op: "Post", URL: "https://api.cryptvault.cloud/query", Err: &net.DNSError{Err: "no such host", Name: "api.cryptvault.cloud"}}, expected: ExitNetwork},
+		{name: "unknown flag", err: errors.New("flag provided but not defined: -x"), expected: ExitUsage},
+		{name: "required flag", err: errors.New(`Required flag "name" not set`), expected: ExitUsage},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := exitCode(tt.err); got != tt.expected {
+				t.Fatalf("got %d, expected %d", got, tt.expected)
+			}
+		})
+	}
+}
@@ -1,9 +1,12 @@
 package main
 
 import (
+	"errors"
 	"fmt"
+	"io/fs"
 	"os"
 	"path"
+	"path/filepath"
 	"strings"
 )
 
@@ -15,6 +18,8 @@ type FileHandling interface {
 	SelectedVault() (string, error)
 	DeleteFolder(filePath string) error
 	FullPath(filePath string) string
+	ListFiles(folderPath string) ([]string, error)
+	AppendTextToFile(filePath string, content string) error
 }
 
 type FileHandlerMock struct {
@@ -30,6 +35,9 @@ func (f *FileHandlerMock) Init() error {
 func (f *FileHandlerMock) SaveTextToFile(filePath string, content string) error {
 	return nil
 }
+func (f *FileHandlerMock) AppendTextToFile(filePath string, content string) error {
+	return nil
+}
 func (f *FileHandlerMock) ReadTextFile(filePath string) (string, error) {
 	return "", fmt.Errorf("Not found")
 }
@@ -45,6 +53,10 @@ func (f *FileHandlerMock) DeleteFolder(filePath string) error {
 	return nil
 }
 
+func (f *FileHandlerMock) ListFiles(folderPath string) ([]string, error) {
+	return []string{}, nil
+}
+
 type FileHandler struct {
 	RootPath string
 }
@@ -82,6 +94,20 @@ func (f *FileHandler) SaveTextToFile(filePath string, content string) error {
 	return os.WriteFile(p, []byte(content), 0600)
 }
 
+// AppendTextToFile appends content to a file, the file is created if it does not exist.
+func (f *FileHandler) AppendTextToFile(filePath string, content string) error {
+	p := f.FullPath(filePath)
+	if err := os.MkdirAll(path.Dir(p), 0700); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(p, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	_, err = file.WriteString(content)
+	return errors.Join(err, file.Close())
+}
+
 func (f *FileHandler) ReadTextFile(filePath string) (string, error) {
 	res, err := os.ReadFile(f.FullPath(filePath))
 	if err != nil {
@@ -97,9 +123,34 @@ func (f *FileHandler) AvailableVaults() ([]string, error) {
 	}
 	result := make([]string, 0)
 	for _, v := range dirs {
-		if v.IsDir() {
+		// folders like .trash are no vaults
+		if v.IsDir() && !strings.HasPrefix(v.Name(), ".") {
 			result = append(result, v.Name())
 		}
 	}
 	return result, nil
 }
+
+// ListFiles returns all files below folderPath recursive, relative to folderPath.
+func (f *FileHandler) ListFiles(folderPath string) ([]string, error) {
+	root := f.FullPath(folderPath)
+	result := make([]string, 0)
+	err := filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(root, p)
+		if err != nil {
+			return err
+		}
+		result = append(result, filepath.ToSlash(rel))
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/Khan/genqlient/graphql"
+	client "github.com/cryptvault-cloud/api"
+	"github.com/cryptvault-cloud/helper"
+	"github.com/urfave/cli/v2"
+)
+
+// publicKeyFingerprint returns a short SHA-256 based fingerprint of the DER encoded public key,
+// something like 1a2b 3c4d 5e6f 7a8b 9c0d 1e2f 3a4b 5c6d.
+func publicKeyFingerprint(key *ecdsa.PublicKey) (string, error) {
+	der, err := x509.MarshalPKIXPublicKey(key)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(der)
+	hexSum := hex.EncodeToString(sum[:16])
+	groups := make([]string, 0, len(hexSum)/4)
+	for i := 0; i < len(hexSum); i += 4 {
+		groups = append(groups, hexSum[i:i+4])
+	}
+	return strings.Join(groups, " "), nil
+}
+
+// fingerprintOrUnknown is used for output where a broken key should not stop the whole listing.
+func fingerprintOrUnknown(key *ecdsa.PublicKey) string {
+	fingerprint, err := publicKeyFingerprint(key)
+	if err != nil {
+		return "unknown"
+	}
+	return fingerprint
+}
+
+func GetFingerprintCommand() *cli.Command {
+	return &cli.Command{
+		Name:        "fingerprint",
+		Usage:       "Print the fingerprint of a public key",
+		Description: "Compare the fingerprint with the one shown by the owner of the key, f.e. over a call, before you register or trust an identity.",
+		Action:      printFingerprint,
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:     CliFingerprintPublicKey,
+				Usage:    "Public key as b64, pem or OpenSSH authorized key",
+				Required: true,
+			},
+			&cli.StringFlag{
+				Name:  CliFingerprintVaultId,
+				Usage: "Also print the identity id of the key at this vault",
+			},
+		},
+	}
+}
+
+func printFingerprint(c *cli.Context) error {
+	pubKey, err := parsePublicKey(c.String(CliFingerprintPublicKey))
+	if err != nil {
+		return err
+	}
+	fingerprint, err := publicKeyFingerprint(pubKey)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Fingerprint: %s\n", fingerprint)
+	if vaultId := c.String(CliFingerprintVaultId); vaultId != "" {
+		identityId, err := identityIdOfKey(pubKey, vaultId)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("ID: %s\n", identityId)
+	}
+	return nil
+}
+
+const identitiesQuery = `
+query identities {
+	queryIdentity {
+		data {
+			id
+			name
+			publicKey
+			rights {
+				right
+				rightValuePattern
+			}
+		}
+	}
+}
+`
+
+// queriedIdentity is an identity with id and public key, both are missing at GetAllIdentities of the api library.
+type queriedIdentity struct {
+	Id        string                 `json:"id"`
+	Name      *string                `json:"name"`
+	PublicKey helper.Base64PublicPem `json:"publicKey"`
+	Rights    []struct {
+		Right             client.Directions `json:"right"`
+		RightValuePattern string            `json:"rightValuePattern"`
+	} `json:"rights"`
+}
+
+type identitiesResponse struct {
+	QueryIdentity struct {
+		Data []*queriedIdentity `json:"data"`
+	} `json:"queryIdentity"`
+}
+
+// jwtTransport signs every request like the api library does for protected calls.
+type jwtTransport struct {
+	key     *ecdsa.PrivateKey
+	vaultId string
+}
+
+func (t *jwtTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	jwt, err := helper.SignJWT(t.key, t.vaultId)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", jwt))
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+// queryIdentities returns all identities of the vault with id, public key and rights.
+func (r *ProtectedRunner) queryIdentities(c *cli.Context) ([]*queriedIdentity, error) {
+	httpClient := &http.Client{Transport: &jwtTransport{key: r.privateKey, vaultId: *r.vaultId}}
+	gqlClient := graphql.NewClient(c.String(CliServerUrl), httpClient)
+	data := &identitiesResponse{}
+	err := gqlClient.MakeRequest(context.Background(), &graphql.Request{
+		OpName: "identities",
+		Query:  identitiesQuery,
+	}, &graphql.Response{Data: data})
+	if err != nil {
+		return nil, err
+	}
+	return data.QueryIdentity.Data, nil
+}
+
+// fingerprint returns the fingerprint of the public key or unknown if the key can not be parsed.
+func (i *queriedIdentity) fingerprint() string {
+	pubKey, err := i.PublicKey.GetPublicKey()
+	if err != nil {
+		return "unknown"
+	}
+	return fingerprintOrUnknown(pubKey)
+}
@@ -0,0 +1,343 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/urfave/cli/v2"
+)
+
+// localIdentityInfo describes an identity of the local workspace.
+type localIdentityInfo struct {
+	Name        string `json:"name"`
+	VaultId     string `json:"vaultId"`
+	IdentityId  string `json:"identityId"`
+	Fingerprint string `json:"fingerprint"`
+	PublicKey   string `json:"publicKey"`
+	PrivateKey  string `json:"privateKey,omitempty"`
+}
+
+func localVaultFlag() cli.Flag {
+	return &cli.StringFlag{
+		Name:  CliLocalIdentityVault,
+		Usage: "Name of the local vault, the selected vault if not set",
+	}
+}
+
+func localIdentityNameFlag() cli.Flag {
+	return &cli.StringFlag{
+		Name:     CliLocalIdentityName,
+		Usage:    "Name of the local identity, use operator for the operator key",
+		Required: true,
+	}
+}
+
+func GetLocalIdentityCommands(runner *Runner) []*cli.Command {
+	checkServerFlag := &cli.BoolFlag{
+		Name:  CliLocalIdentityCheckServer,
+		Usage: "Query the server if the identity is registered",
+	}
+	return []*cli.Command{
+		{
+			Name:    "ls",
+			Aliases: []string{"list"},
+			Usage:   "List local information",
+			Subcommands: []*cli.Command{
+				{
+					Name:   "identities",
+					Usage:  "show all identities of a local vault",
+					Action: runner.LocalListIdentities,
+					Flags:  []cli.Flag{localVaultFlag(), checkServerFlag},
+				},
+			},
+		},
+		{
+			Name:  "show",
+			Usage: "Show local information",
+			Subcommands: []*cli.Command{
+				{
+					Name:   "identity",
+					Usage:  "show details of a local identity",
+					Action: runner.LocalShowIdentity,
+					Flags:  []cli.Flag{localVaultFlag(), localIdentityNameFlag(), checkServerFlag},
+				},
+			},
+		},
+		{
+			Name:  "rm",
+			Usage: "Remove local information",
+			Subcommands: []*cli.Command{
+				{
+					Name:        "identity",
+					Usage:       "remove a local identity",
+					Description: "Only the local files are moved to the trash, the identity stays registered at the server.",
+					Action:      runner.audited(runner.LocalRemoveIdentity),
+					Flags: []cli.Flag{
+						localVaultFlag(),
+						localIdentityNameFlag(),
+						&cli.BoolFlag{
+							Name:  CliDeleteYes,
+							Usage: "Remove the operator key without asking",
+						},
+					},
+				},
+			},
+		},
+		{
+			Name:  "mv",
+			Usage: "Rename local information",
+			Subcommands: []*cli.Command{
+				{
+					Name:   "identity",
+					Usage:  "rename a local identity",
+					Action: runner.audited(runner.LocalMoveIdentity),
+					Flags: []cli.Flag{
+						localVaultFlag(),
+						localIdentityNameFlag(),
+						&cli.StringFlag{
+							Name:     CliLocalIdentityNewName,
+							Usage:    "New name of the local identity",
+							Required: true,
+						},
+					},
+				},
+			},
+		},
+		{
+			Name:  "export",
+			Usage: "Export local information",
+			Subcommands: []*cli.Command{
+				{
+					Name:   "identity",
+					Usage:  "print the key of a local identity, something to hand over to a CI system",
+					Action: runner.LocalExportIdentity,
+					Flags: []cli.Flag{
+						localVaultFlag(),
+						localIdentityNameFlag(),
+						&cli.StringFlag{
+							Name:  CliLocalIdentityFormat,
+							Usage: "Format of the key b64, pem (SEC1), pkcs8, openssh or json",
+							Value: KeyFormatB64,
+						},
+						&cli.BoolFlag{
+							Name:  CliLocalIdentityPublicOnly,
+							Usage: "Only export the public key",
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+// localVault returns the vault given by flag or the selected vault.
+func (r *Runner) localVault(c *cli.Context) (string, error) {
+	if vaultName := c.String(CliLocalIdentityVault); vaultName != "" {
+		if err := validWorkspaceName(vaultName); err != nil {
+			return "", fmt.Errorf("invalid vault name: %w", err)
+		}
+		return vaultName, nil
+	}
+	vaultName, err := r.fileHandler.SelectedVault()
+	return strings.TrimSpace(vaultName), err
+}
+
+func (r *Runner) localIdentityInfo(vaultName, name string) (*localIdentityInfo, error) {
+	vaultId, err := r.vaultIdByName(vaultName)
+	if err != nil {
+		return nil, err
+	}
+	key, err := r.identityKey(vaultName, name)
+	if err != nil {
+		return nil, err
+	}
+	identityId, err := identityIdOfKey(&key.PublicKey, vaultId)
+	if err != nil {
+		return nil, err
+	}
+	fingerprint, err := publicKeyFingerprint(&key.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+	publicKey, err := encodePublicKey(&key.PublicKey, KeyFormatB64)
+	if err != nil {
+		return nil, err
+	}
+	return &localIdentityInfo{Name: name, VaultId: vaultId, IdentityId: identityId, Fingerprint: fingerprint, PublicKey: publicKey}, nil
+}
+
+// registeredAtServer asks the server for the identity by using its own key.
+func (r *Runner) registeredAtServer(vaultName, name string, info *localIdentityInfo) string {
+	key, err := r.identityKey(vaultName, name)
+	if err != nil {
+		return "unknown"
+	}
+	identity, err := r.api.GetProtectedApi(key, info.VaultId).GetIdentity(info.IdentityId)
+	if err != nil || identity == nil {
+		return "no"
+	}
+	return "yes"
+}
+
+func (r *Runner) LocalListIdentities(c *cli.Context) error {
+	vaultName, err := r.localVault(c)
+	if err != nil {
+		return err
+	}
+	names, err := r.localIdentities(vaultName)
+	if err != nil {
+		return err
+	}
+	if _, err := r.identityKey(vaultName, OperatorIdentityName); err == nil {
+		names = append([]string{OperatorIdentityName}, names...)
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tID\tFINGERPRINT\tREGISTERED")
+	for _, name := range names {
+		info, err := r.localIdentityInfo(vaultName, name)
+		if err != nil {
+			fmt.Fprintf(w, "%s\t-\t-\terror: %s\n", name, err)
+			continue
+		}
+		registered := "-"
+		if c.Bool(CliLocalIdentityCheckServer) {
+			registered = r.registeredAtServer(vaultName, name, info)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", name, info.IdentityId, info.Fingerprint, registered)
+	}
+	return w.Flush()
+}
+
+func (r *Runner) LocalShowIdentity(c *cli.Context) error {
+	vaultName, err := r.localVault(c)
+	if err != nil {
+		return err
+	}
+	name := c.String(CliLocalIdentityName)
+	info, err := r.localIdentityInfo(vaultName, name)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Name: %s\nVault: %s (%s)\nID: %s\nFingerprint: %s\nFolder: %s\n", info.Name, vaultName, info.VaultId, info.IdentityId, info.Fingerprint, r.fileHandler.FullPath(identityFolder(vaultName, name)))
+	if c.Bool(CliLocalIdentityCheckServer) {
+		fmt.Printf("Registered: %s\n", r.registeredAtServer(vaultName, name, info))
+	}
+	fmt.Printf("Public key:\n%s\n", info.PublicKey)
+	return nil
+}
+
+func (r *Runner) LocalRemoveIdentity(c *cli.Context) error {
+	vaultName, err := r.localVault(c)
+	if err != nil {
+		return err
+	}
+	name := c.String(CliLocalIdentityName)
+	if err := validWorkspaceName(name); err != nil {
+		return fmt.Errorf("invalid identity name: %w", err)
+	}
+	folder := identityFolder(vaultName, name)
+	if _, err := r.identityKey(vaultName, name); err != nil {
+		return err
+	}
+	if name == OperatorIdentityName {
+		if err := confirmDestructive(c, fmt.Sprintf("Remove the operator key of vault %s? Without it the vault can not be managed from this workspace", vaultName)); err != nil {
+			return err
+		}
+	}
+	trashId, err := r.moveToTrash(folder)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Local identity %s moved to the trash as %s, it is still registered at the server\n", name, trashId)
+	return nil
+}
+
+func (r *Runner) LocalMoveIdentity(c *cli.Context) error {
+	vaultName, err := r.localVault(c)
+	if err != nil {
+		return err
+	}
+	name := c.String(CliLocalIdentityName)
+	newName := c.String(CliLocalIdentityNewName)
+	if name == OperatorIdentityName || newName == OperatorIdentityName {
+		return fmt.Errorf("the operator key can not be renamed")
+	}
+	if err := validWorkspaceName(name); err != nil {
+		return fmt.Errorf("invalid identity name: %w", err)
+	}
+	if err := validWorkspaceName(newName); err != nil {
+		return fmt.Errorf("invalid identity name: %w", err)
+	}
+	files, err := r.readFolder(identityFolder(vaultName, name))
+	if err != nil {
+		return err
+	}
+	if files == nil {
+		return fmt.Errorf("identity %s not found at vault %s", name, vaultName)
+	}
+	if existing, err := r.readFolder(identityFolder(vaultName, newName)); err != nil {
+		return err
+	} else if existing != nil {
+		return withExitCode(ExitConflict, fmt.Errorf("identity %s already exists at vault %s", newName, vaultName))
+	}
+	for f, content := range files {
+		err = errors.Join(r.fileHandler.SaveTextToFile(fmt.Sprintf("%s/%s", identityFolder(vaultName, newName), f), content), err)
+	}
+	if err != nil {
+		return err
+	}
+	if err := r.fileHandler.DeleteFolder(identityFolder(vaultName, name)); err != nil {
+		return err
+	}
+	fmt.Printf("Local identity %s renamed to %s, the name at the server is unchanged\n", name, newName)
+	return nil
+}
+
+func (r *Runner) LocalExportIdentity(c *cli.Context) error {
+	vaultName, err := r.localVault(c)
+	if err != nil {
+		return err
+	}
+	name := c.String(CliLocalIdentityName)
+	format := c.String(CliLocalIdentityFormat)
+	publicOnly := c.Bool(CliLocalIdentityPublicOnly)
+	key, err := r.identityKey(vaultName, name)
+	if err != nil {
+		return err
+	}
+
+	if format == KeyFormatJSON {
+		info, err := r.localIdentityInfo(vaultName, name)
+		if err != nil {
+			return err
+		}
+		if !publicOnly {
+			info.PrivateKey, err = encodePrivateKey(key, KeyFormatB64)
+			if err != nil {
+				return err
+			}
+		}
+		out, err := json.MarshalIndent(info, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(out))
+		return nil
+	}
+
+	var out string
+	if publicOnly {
+		out, err = encodePublicKey(&key.PublicKey, format)
+	} else {
+		out, err = encodePrivateKey(key, format)
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Println(strings.TrimSpace(out))
+	return nil
+}
@@ -0,0 +1,258 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	client "github.com/cryptvault-cloud/api"
+	"github.com/urfave/cli/v2"
+)
+
+var (
+	k8sNameRegex = regexp.MustCompile(`^[a-z0-9]([-a-z0-9.]*[a-z0-9])?$`)
+	k8sKeyRegex  = regexp.MustCompile(`^[-._a-zA-Z0-9]+$`)
+)
+
+// k8sSecret is the subset of a v1/Secret which is rendered.
+type k8sSecret struct {
+	name        string
+	namespace   string
+	secretType  string
+	labels      map[string]string
+	annotations map[string]string
+	data        map[string]string
+}
+
+func GetK8sCommand(pRunner *ProtectedRunner) *cli.Command {
+	return &cli.Command{
+		Name:  "k8s",
+		Usage: "Render Kubernetes manifests from values",
+		Subcommands: []*cli.Command{
+			{
+				Name:        "secret",
+				Usage:       "Render a v1/Secret manifest ready for kubectl apply",
+				Description: "--map adds one key for one value, --from-prefix adds all readable values below the prefix with their name relative to the prefix as key.",
+				Action:      pRunner.K8sSecret,
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:     CliK8sName,
+						Usage:    "Name of the secret",
+						Required: true,
+					},
+					&cli.StringFlag{
+						Name:  CliK8sNamespace,
+						Usage: "Namespace of the secret",
+					},
+					&cli.StringFlag{
+						Name:  CliK8sType,
+						Usage: "Type of the secret",
+						Value: "Opaque",
+					},
+					&cli.StringSliceFlag{
+						Name:  CliK8sMap,
+						Usage: "KEY=VALUES.a.b, can be set multiple times",
+					},
+					&cli.StringSliceFlag{
+						Name:  CliK8sFromPrefix,
+						Usage: "Prefix like VALUES.app.env, can be set multiple times",
+					},
+					&cli.StringSliceFlag{
+						Name:  CliK8sLabel,
+						Usage: "key=value label, can be set multiple times",
+					},
+					&cli.StringSliceFlag{
+						Name:  CliK8sAnnotation,
+						Usage: "key=value annotation, can be set multiple times",
+					},
+					&cli.BoolFlag{
+						Name:  CliK8sExpandJson,
+						Usage: "Add one key per field of JSON object values instead of one key for the value",
+					},
+					&cli.StringFlag{
+						Name:  CliK8sOut,
+						Usage: "Write the manifest to this file instead of stdout",
+					},
+				},
+			},
+		},
+	}
+}
+
+// parseKeyValues parses key=value pairs of flag into a map.
+func parseKeyValues(flag string, pairs []string) (map[string]string, error) {
+	result := make(map[string]string)
+	for _, pair := range pairs {
+		key, value, found := strings.Cut(pair, "=")
+		if !found || key == "" {
+			return nil, withExitCode(ExitUsage, fmt.Errorf("invalid --%s %s, expected key=value", flag, pair))
+		}
+		result[key] = value
+	}
+	return result, nil
+}
+
+func (r *ProtectedRunner) K8sSecret(c *cli.Context) error {
+	secret := &k8sSecret{
+		name:       c.String(CliK8sName),
+		namespace:  c.String(CliK8sNamespace),
+		secretType: c.String(CliK8sType),
+		data:       make(map[string]string),
+	}
+	if !k8sNameRegex.MatchString(secret.name) {
+		return withExitCode(ExitUsage, fmt.Errorf("invalid secret name %s, has to be a lowercase DNS subdomain", secret.name))
+	}
+	var err error
+	if secret.labels, err = parseKeyValues(CliK8sLabel, c.StringSlice(CliK8sLabel)); err != nil {
+		return err
+	}
+	if secret.annotations, err = parseKeyValues(CliK8sAnnotation, c.StringSlice(CliK8sAnnotation)); err != nil {
+		return err
+	}
+	mapped, err := parseKeyValues(CliK8sMap, c.StringSlice(CliK8sMap))
+	if err != nil {
+		return err
+	}
+	prefixes := c.StringSlice(CliK8sFromPrefix)
+	if len(mapped) == 0 && len(prefixes) == 0 {
+		return withExitCode(ExitUsage, fmt.Errorf("at least one --%s or --%s is needed", CliK8sMap, CliK8sFromPrefix))
+	}
+	expandJson := c.Bool(CliK8sExpandJson)
+
+	for _, prefix := range prefixes {
+		prefix = strings.TrimSuffix(prefix, ".")
+		if !ValueNameRegex.MatchString(prefix) {
+			return withExitCode(ExitUsage, fmt.Errorf("invalid prefix %s", prefix))
+		}
+		identityId, err := identityIdOfKey(&r.privateKey.PublicKey, *r.vaultId)
+		if err != nil {
+			return err
+		}
+		related, err := r.api.GetAllRelatedValues(identityId)
+		if err != nil {
+			return err
+		}
+		found := false
+		for _, v := range related {
+			key, isBelow := strings.CutPrefix(v.Name, prefix+".")
+			if !isBelow {
+				continue
+			}
+			found = true
+			value, err := r.api.GetIdentityValueById(v.Id)
+			if err != nil {
+				return fmt.Errorf("%s: %w", v.Name, err)
+			}
+			if err := secret.add(key, value, expandJson); err != nil {
+				return err
+			}
+		}
+		if !found {
+			return withExitCode(ExitNotFound, fmt.Errorf("no readable values below %s", prefix))
+		}
+	}
+	keys := make([]string, 0, len(mapped))
+	for key := range mapped {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		value, err := r.api.GetIdentityValueByName(mapped[key])
+		if err != nil {
+			return fmt.Errorf("%s: %w", mapped[key], err)
+		}
+		if err := secret.add(key, value, expandJson); err != nil {
+			return err
+		}
+	}
+
+	buf := &bytes.Buffer{}
+	secret.render(buf)
+	if out := c.String(CliK8sOut); out != "" {
+		return os.WriteFile(out, buf.Bytes(), 0600)
+	}
+	_, err = os.Stdout.Write(buf.Bytes())
+	return err
+}
+
+// add adds value as key or, if expandJson is set and value is a JSON object, one key per field.
+func (s *k8sSecret) add(key string, value *client.IdentityValue, expandJson bool) error {
+	if expandJson && value.Type == client.ValueTypeJson {
+		fields := make(map[string]json.RawMessage)
+		if err := json.Unmarshal([]byte(value.Value), &fields); err == nil {
+			for field, raw := range fields {
+				var str string
+				if err := json.Unmarshal(raw, &str); err != nil {
+					// keep numbers, bools and nested objects as JSON
+					str = string(raw)
+				}
+				if err := s.set(field, str, value.Name); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+	}
+	return s.set(key, value.Value, value.Name)
+}
+
+func (s *k8sSecret) set(key, value, valueName string) error {
+	if !k8sKeyRegex.MatchString(key) {
+		return fmt.Errorf("key %s of %s is no valid secret key", key, valueName)
+	}
+	if _, exists := s.data[key]; exists {
+		return withExitCode(ExitConflict, fmt.Errorf("key %s of %s is set twice", key, valueName))
+	}
+	s.data[key] = value
+	return nil
+}
+
+// yamlQuote quotes s as double quoted scalar, JSON strings are valid YAML.
+func yamlQuote(s string) string {
+	buf := &bytes.Buffer{}
+	enc := json.NewEncoder(buf)
+	enc.SetEscapeHTML(false)
+	enc.Encode(s)
+	return strings.TrimSuffix(buf.String(), "\n")
+}
+
+func writeYamlMap(w io.Writer, indent string, m map[string]string, encode func(string) string) {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		fmt.Fprintf(w, "%s%s: %s\n", indent, yamlQuote(key), yamlQuote(encode(m[key])))
+	}
+}
+
+func (s *k8sSecret) render(w io.Writer) {
+	plain := func(v string) string { return v }
+	fmt.Fprintln(w, "apiVersion: v1")
+	fmt.Fprintln(w, "kind: Secret")
+	fmt.Fprintln(w, "metadata:")
+	fmt.Fprintf(w, "  name: %s\n", yamlQuote(s.name))
+	if s.namespace != "" {
+		fmt.Fprintf(w, "  namespace: %s\n", yamlQuote(s.namespace))
+	}
+	if len(s.labels) > 0 {
+		fmt.Fprintln(w, "  labels:")
+		writeYamlMap(w, "    ", s.labels, plain)
+	}
+	if len(s.annotations) > 0 {
+		fmt.Fprintln(w, "  annotations:")
+		writeYamlMap(w, "    ", s.annotations, plain)
+	}
+	fmt.Fprintf(w, "type: %s\n", yamlQuote(s.secretType))
+	fmt.Fprintln(w, "data:")
+	writeYamlMap(w, "  ", s.data, func(v string) string {
+		return base64.StdEncoding.EncodeToString([]byte(v))
+	})
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestYamlQuote(t *testing.T) {
+	tests := []struct {
+		name     string
+		value    string
+		expected string
+	}{
+		{name: "plain", value: "app-db", expected: `"app-db"`},
+		{name: "empty", value: "", expected: `""`},
+		{name: "boolean like", value: "yes", expected: `"yes"`},
+		{name: "number like", value: "0755", expected: `"0755"`},
+		{name: "null like", value: "~", expected: `"~"`},
+		{name: "double quote", value: `say "hi"`, expected: `"say \"hi\""`},
+		{name: "backslash", value: `C:\path`, expected: `"C:\\path"`},
+		{name: "newline", value: "line1\nline2", expected: `"line1\nline2"`},
+		{name: "tab", value: "a\tb", expected: `"a\tb"`},
+		{name: "control character", value: "a\x01b", expected: `"a\u0001b"`},
+		{name: "comment and mapping", value: "#x: y", expected: `"#x: y"`},
+		{name: "flow and anchor characters", value: "{a}, [b], &c *d !e |f >g", expected: `"{a}, [b], &c *d !e |f >g"`},
+		{name: "html is not escaped", value: "<a>&", expected: `"<a>&"`},
+		{name: "unicode", value: "grüße", expected: `"grüße"`},
+		{name: "line separator", value: "a\u2028b", expected: `"a\u2028b"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := yamlQuote(tt.value)
+			if got != tt.expected {
+				t.Fatalf("got %s, expected %s", got, tt.expected)
+			}
+			// yaml double quoted scalars use the json escapes, so the value has to decode to the input
+			var decoded string
+			if err := json.Unmarshal([]byte(got), &decoded); err != nil || decoded != tt.value {
+				t.Fatalf("%s decodes to %q (%v), expected %q", got, decoded, err, tt.value)
+			}
+		})
+	}
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/cryptvault-cloud/helper"
+	"golang.org/x/crypto/ssh"
+)
+
+const (
+	KeyFormatB64     = "b64"
+	KeyFormatPem     = "pem"
+	KeyFormatPkcs8   = "pkcs8"
+	KeyFormatOpenSSH = "openssh"
+	KeyFormatJSON    = "json"
+)
+
+// checkCurve makes sure the key uses the curve of all cryptvault keys.
+func checkCurve(key *ecdsa.PublicKey) error {
+	if key.Curve != elliptic.P521() {
+		return fmt.Errorf("key uses curve %s, only %s keys are supported by cryptvault", key.Curve.Params().Name, elliptic.P521().Params().Name)
+	}
+	return nil
+}
+
+// encodePrivateKey encodes key in a format other tools understand.
+// b64 is the format of the helper library which is used at the workspace, pem is a SEC1 EC PRIVATE KEY.
+func encodePrivateKey(key *ecdsa.PrivateKey, format string) (string, error) {
+	switch format {
+	case KeyFormatB64:
+		return helper.GetB64FromPrivateKey(key)
+	case KeyFormatPem:
+		der, err := x509.MarshalECPrivateKey(key)
+		if err != nil {
+			return "", err
+		}
+		return string(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der})), nil
+	case KeyFormatPkcs8:
+		der, err := x509.MarshalPKCS8PrivateKey(key)
+		if err != nil {
+			return "", err
+		}
+		return string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})), nil
+	case KeyFormatOpenSSH:
+		block, err := ssh.MarshalPrivateKey(key, "")
+		if err != nil {
+			return "", err
+		}
+		return string(pem.EncodeToMemory(block)), nil
+	}
+	return "", fmt.Errorf("unsupported private key format %s", format)
+}
+
+// encodePublicKey encodes key as b64 of the helper library, as PKIX PUBLIC KEY pem or as OpenSSH authorized key.
+func encodePublicKey(key *ecdsa.PublicKey, format string) (string, error) {
+	switch format {
+	case KeyFormatB64:
+		return helper.GetB64FromPublicKey(key)
+	case KeyFormatPem, KeyFormatPkcs8:
+		return helper.EncodePublicKey(key)
+	case KeyFormatOpenSSH:
+		sshKey, err := ssh.NewPublicKey(key)
+		if err != nil {
+			return "", err
+		}
+		return string(ssh.MarshalAuthorizedKey(sshKey)), nil
+	}
+	return "", fmt.Errorf("unsupported public key format %s", format)
+}
+
+// parsePrivateKey accepts the b64 format of the helper library, SEC1 and PKCS#8 pem and OpenSSH ECDSA keys.
+func parsePrivateKey(value string) (*ecdsa.PrivateKey, error) {
+	value = strings.TrimSpace(value)
+	if !strings.HasPrefix(value, "-----BEGIN") {
+		decoded, err := base64.StdEncoding.DecodeString(value)
+		if err != nil {
+			return nil, fmt.Errorf("private key is neither pem nor base64: %w", err)
+		}
+		value = strings.TrimSpace(string(decoded))
+		if !strings.HasPrefix(value, "-----BEGIN") {
+			return nil, fmt.Errorf("private key is not a pem after base64 decoding")
+		}
+	}
+	block, _ := pem.Decode([]byte(value))
+	if block == nil {
+		return nil, fmt.Errorf("private key is not a valid pem")
+	}
+
+	var key any
+	var err error
+	switch block.Type {
+	case "EC PRIVATE KEY":
+		key, err = x509.ParseECPrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		// the helper library writes SEC1 keys with this type, so both are tried
+		key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			key, err = x509.ParseECPrivateKey(block.Bytes)
+		}
+	case "OPENSSH PRIVATE KEY":
+		key, err = ssh.ParseRawPrivateKey([]byte(value))
+		var missing *ssh.PassphraseMissingError
+		if errors.As(err, &missing) {
+			return nil, fmt.Errorf("private key is protected by a passphrase, decrypt it first f.e. with ssh-keygen -p")
+		}
+	case "ENCRYPTED PRIVATE KEY":
+		return nil, fmt.Errorf("encrypted private keys are not supported, decrypt it first f.e. with openssl pkcs8")
+	default:
+		return nil, fmt.Errorf("unsupported private key type %s", block.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+	ecdsaKey, ok := key.(*ecdsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("private key is a %T, only ecdsa keys are supported", key)
+	}
+	if err := checkCurve(&ecdsaKey.PublicKey); err != nil {
+		return nil, err
+	}
+	return ecdsaKey, nil
+}
+
+// parsePublicKey accepts the b64 format of the helper library, a PUBLIC KEY pem or an OpenSSH authorized key.
+func parsePublicKey(value string) (*ecdsa.PublicKey, error) {
+	value = strings.TrimSpace(value)
+	if strings.HasPrefix(value, "ecdsa-sha2-") {
+		sshKey, _, _, _, err := ssh.ParseAuthorizedKey([]byte(value))
+		if err != nil {
+			return nil, err
+		}
+		cryptoKey, ok := sshKey.(ssh.CryptoPublicKey)
+		if !ok {
+			return nil, fmt.Errorf("unsupported ssh public key")
+		}
+		ecdsaKey, ok := cryptoKey.CryptoPublicKey().(*ecdsa.PublicKey)
+		if !ok {
+			return nil, fmt.Errorf("public key is not an ecdsa key")
+		}
+		return ecdsaKey, checkCurve(ecdsaKey)
+	}
+	if !strings.HasPrefix(value, "-----BEGIN") {
+		decoded, err := base64.StdEncoding.DecodeString(value)
+		if err != nil {
+			return nil, fmt.Errorf("public key is neither pem, ssh nor base64: %w", err)
+		}
+		value = string(decoded)
+	}
+	block, _ := pem.Decode([]byte(value))
+	if block == nil {
+		return nil, fmt.Errorf("public key is not a valid pem")
+	}
+	pub, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	ecdsaKey, ok := pub.(*ecdsa.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("public key is not an ecdsa key")
+	}
+	return ecdsaKey, checkCurve(ecdsaKey)
+}
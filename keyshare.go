@@ -0,0 +1,367 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/cryptvault-cloud/helper"
+	"github.com/urfave/cli/v2"
+)
+
+const (
+	keySharePemType    = "CRYPTVAULT KEY SHARE"
+	keyShareTextPrefix = "cvks1"
+)
+
+// keyShare is one Shamir share of an operator key.
+type keyShare struct {
+	Vault     string
+	Index     byte
+	Shares    int
+	Threshold int
+	// SecretChecksum is used to verify the combined key
+	SecretChecksum string
+	Data           []byte
+}
+
+func GetLocalKeyCommand(runner *Runner) *cli.Command {
+	return &cli.Command{
+		Name:  "key",
+		Usage: "Backup the operator key as Shamir secret shares",
+		Subcommands: []*cli.Command{
+			{
+				Name:        "split",
+				Usage:       "Split <vault>/operator/key into shares",
+				Description: "Any threshold of the shares can restore the operator key, less shares do not reveal anything about it.",
+				Action:      runner.LocalKeySplit,
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:     CliKeyShareVault,
+						Usage:    "Name of the local vault",
+						Required: true,
+					},
+					&cli.IntFlag{
+						Name:  CliKeyShareShares,
+						Usage: "Number of shares to create",
+						Value: 5,
+					},
+					&cli.IntFlag{
+						Name:  CliKeyShareThreshold,
+						Usage: "Number of shares required to restore the key",
+						Value: 3,
+					},
+					&cli.StringFlag{
+						Name:  CliKeyShareFormat,
+						Usage: "Format of the shares armor or text",
+						Value: "armor",
+					},
+					&cli.StringFlag{
+						Name:  CliKeyShareOutDir,
+						Usage: "Write every share to its own file at this folder instead of printing them",
+					},
+				},
+			},
+			{
+				Name:        "combine",
+				Usage:       "Restore <vault>/operator/key from shares",
+				Description: "Shares are read from the given files or from stdin, armored and text shares can be mixed.",
+				Action:      runner.audited(runner.LocalKeyCombine),
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:     CliKeyShareVault,
+						Usage:    "Name of the local vault",
+						Required: true,
+					},
+					&cli.StringSliceFlag{
+						Name:  CliKeyShareIn,
+						Usage: "Files with shares, if not set shares are read from stdin",
+					},
+					&cli.BoolFlag{
+						Name:  CliKeyShareForce,
+						Usage: "Overwrite an existing operator key",
+					},
+				},
+			},
+		},
+	}
+}
+
+func keyShareChecksum(share *keyShare) string {
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%d:%d:%d:%s:", share.Index, share.Shares, share.Threshold, share.SecretChecksum)))
+	h := sha256.New()
+	h.Write(sum[:])
+	h.Write(share.Data)
+	return hex.EncodeToString(h.Sum(nil)[:4])
+}
+
+func secretChecksum(secret []byte) string {
+	sum := sha256.Sum256(secret)
+	return hex.EncodeToString(sum[:8])
+}
+
+func (s *keyShare) armor() string {
+	return string(pem.EncodeToMemory(&pem.Block{
+		Type: keySharePemType,
+		Headers: map[string]string{
+			"Vault":           s.Vault,
+			"Share":           fmt.Sprintf("%d/%d", s.Index, s.Shares),
+			"Threshold":       strconv.Itoa(s.Threshold),
+			"Secret-Checksum": s.SecretChecksum,
+			"Checksum":        keyShareChecksum(s),
+		},
+		Bytes: s.Data,
+	}))
+}
+
+func (s *keyShare) text() string {
+	return strings.Join([]string{
+		keyShareTextPrefix,
+		fmt.Sprintf("%d/%d", s.Index, s.Shares),
+		strconv.Itoa(s.Threshold),
+		s.SecretChecksum,
+		base64.RawURLEncoding.EncodeToString(s.Data),
+		keyShareChecksum(s),
+	}, ":")
+}
+
+func parseShareIndex(str string) (byte, int, error) {
+	indexStr, sharesStr, found := strings.Cut(str, "/")
+	if !found {
+		return 0, 0, fmt.Errorf("invalid share number %s", str)
+	}
+	index, err := strconv.Atoi(indexStr)
+	if err != nil || index < 1 || index > 255 {
+		return 0, 0, fmt.Errorf("invalid share number %s", str)
+	}
+	shares, err := strconv.Atoi(sharesStr)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid share number %s", str)
+	}
+	return byte(index), shares, nil
+}
+
+func parseArmoredShare(block *pem.Block) (*keyShare, error) {
+	index, shares, err := parseShareIndex(block.Headers["Share"])
+	if err != nil {
+		return nil, err
+	}
+	threshold, err := strconv.Atoi(block.Headers["Threshold"])
+	if err != nil {
+		return nil, fmt.Errorf("invalid threshold %s", block.Headers["Threshold"])
+	}
+	share := &keyShare{
+		Vault:          block.Headers["Vault"],
+		Index:          index,
+		Shares:         shares,
+		Threshold:      threshold,
+		SecretChecksum: block.Headers["Secret-Checksum"],
+		Data:           block.Bytes,
+	}
+	if keyShareChecksum(share) != block.Headers["Checksum"] {
+		return nil, fmt.Errorf("share %d is damaged, checksum mismatch", index)
+	}
+	return share, nil
+}
+
+func parseTextShare(line string) (*keyShare, error) {
+	parts := strings.Split(line, ":")
+	if len(parts) != 6 || parts[0] != keyShareTextPrefix {
+		return nil, fmt.Errorf("invalid text share %s", line)
+	}
+	index, shares, err := parseShareIndex(parts[1])
+	if err != nil {
+		return nil, err
+	}
+	threshold, err := strconv.Atoi(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("invalid threshold %s", parts[2])
+	}
+	data, err := base64.RawURLEncoding.DecodeString(parts[4])
+	if err != nil {
+		return nil, fmt.Errorf("share %d is damaged: %w", index, err)
+	}
+	share := &keyShare{
+		Index:          index,
+		Shares:         shares,
+		Threshold:      threshold,
+		SecretChecksum: parts[3],
+		Data:           data,
+	}
+	if keyShareChecksum(share) != parts[5] {
+		return nil, fmt.Errorf("share %d is damaged, checksum mismatch", index)
+	}
+	return share, nil
+}
+
+// parseKeyShares finds all armored and text shares at content.
+// Damaged shares are reported as error but do not stop parsing the others.
+func parseKeyShares(content []byte) ([]*keyShare, error) {
+	shares := make([]*keyShare, 0)
+	var errs error = nil
+	rest := content
+	for {
+		block, next := pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		if block.Type == keySharePemType {
+			share, err := parseArmoredShare(block)
+			if err != nil {
+				errs = errors.Join(errs, err)
+			} else {
+				shares = append(shares, share)
+			}
+		}
+		rest = next
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if !strings.HasPrefix(line, keyShareTextPrefix+":") {
+			continue
+		}
+		share, err := parseTextShare(line)
+		if err != nil {
+			errs = errors.Join(errs, err)
+		} else {
+			shares = append(shares, share)
+		}
+	}
+	return shares, errs
+}
+
+func (r *Runner) LocalKeySplit(c *cli.Context) error {
+	vaultName := c.String(CliKeyShareVault)
+	format := c.String(CliKeyShareFormat)
+	outDir := c.String(CliKeyShareOutDir)
+	if format != "armor" && format != "text" {
+		return fmt.Errorf("unknown format %s", format)
+	}
+	secret, err := r.fileHandler.ReadTextFile(fmt.Sprintf("%s/operator/key", vaultName))
+	if err != nil {
+		return err
+	}
+	secret = strings.TrimSpace(secret)
+	if _, err := helper.GetPrivateKeyFromB64String(secret); err != nil {
+		return fmt.Errorf("operator key of vault %s is invalid: %w", vaultName, err)
+	}
+
+	n := c.Int(CliKeyShareShares)
+	threshold := c.Int(CliKeyShareThreshold)
+	splitted, err := shamirSplit([]byte(secret), n, threshold)
+	if err != nil {
+		return err
+	}
+	checksum := secretChecksum([]byte(secret))
+	indexes := make([]int, 0, len(splitted))
+	for x := range splitted {
+		indexes = append(indexes, int(x))
+	}
+	sort.Ints(indexes)
+
+	if outDir != "" {
+		if err := os.MkdirAll(outDir, 0700); err != nil {
+			return err
+		}
+	}
+	for _, x := range indexes {
+		share := &keyShare{Vault: vaultName, Index: byte(x), Shares: n, Threshold: threshold, SecretChecksum: checksum, Data: splitted[byte(x)]}
+		content := share.armor() + "\n"
+		if format == "text" {
+			content = share.text() + "\n"
+		}
+		if outDir == "" {
+			fmt.Print(content)
+			continue
+		}
+		filePath := path.Join(outDir, fmt.Sprintf("%s-share-%d.txt", vaultName, x))
+		if err := os.WriteFile(filePath, []byte(content), 0600); err != nil {
+			return err
+		}
+		fmt.Printf("Share %d/%d saved at %s\n", x, n, filePath)
+	}
+	fmt.Printf("Any %d of the %d shares restore the operator key of %s, keep them at different places\n", threshold, n, vaultName)
+	return nil
+}
+
+func (r *Runner) LocalKeyCombine(c *cli.Context) error {
+	vaultName := c.String(CliKeyShareVault)
+	keyPath := fmt.Sprintf("%s/operator/key", vaultName)
+	if _, err := r.fileHandler.ReadTextFile(keyPath); err == nil && !c.Bool(CliKeyShareForce) {
+		return fmt.Errorf("operator key of vault %s already exists, use --%s to overwrite it", vaultName, CliKeyShareForce)
+	}
+
+	var content []byte
+	inputs := c.StringSlice(CliKeyShareIn)
+	if len(inputs) == 0 {
+		in, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			return err
+		}
+		content = in
+	}
+	for _, in := range inputs {
+		fileContent, err := os.ReadFile(in)
+		if err != nil {
+			return err
+		}
+		content = append(append(content, fileContent...), '\n')
+	}
+
+	shares, parseErr := parseKeyShares(content)
+	if parseErr != nil {
+		fmt.Printf("Ignored damaged shares:\n%s\n", parseErr)
+	}
+	if len(shares) == 0 {
+		return fmt.Errorf("no valid share found")
+	}
+	first := shares[0]
+	byIndex := make(map[byte][]byte)
+	for _, share := range shares {
+		if share.Threshold != first.Threshold || share.SecretChecksum != first.SecretChecksum {
+			return fmt.Errorf("share %d does not belong to the same key as share %d", share.Index, first.Index)
+		}
+		if share.Vault != "" && share.Vault != vaultName {
+			fmt.Printf("Share %d was created for vault %s\n", share.Index, share.Vault)
+		}
+		byIndex[share.Index] = share.Data
+	}
+	if len(byIndex) < first.Threshold {
+		return fmt.Errorf("got %d valid shares but %d are required", len(byIndex), first.Threshold)
+	}
+
+	secret, err := shamirCombine(byIndex)
+	if err != nil {
+		return err
+	}
+	if secretChecksum(secret) != first.SecretChecksum {
+		return fmt.Errorf("combined key does not match the checksum of the shares")
+	}
+	key, err := helper.GetPrivateKeyFromB64String(string(secret))
+	if err != nil {
+		return err
+	}
+	b64PubKey, err := helper.GetB64FromPublicKey(&key.PublicKey)
+	if err != nil {
+		return err
+	}
+	err = errors.Join(r.fileHandler.SaveTextToFile(fmt.Sprintf("%s/operator/key.pub", vaultName), b64PubKey), err)
+	err = errors.Join(r.fileHandler.SaveTextToFile(keyPath, string(secret)), err)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Operator key restored from %d shares at %s\n", len(byIndex), r.fileHandler.FullPath(fmt.Sprintf("%s/operator", vaultName)))
+	return nil
+}
@@ -1,29 +1,82 @@
 package logger
 
 import (
+	"fmt"
+	"os"
+
 	"go.uber.org/zap/zapcore"
+	"golang.org/x/term"
 
 	"go.uber.org/zap"
 )
 
+const (
+	FormatAuto    = "auto"
+	FormatJSON    = "json"
+	FormatConsole = "console"
+)
+
 var zapLogger = zap.NewNop().Sugar()
 
+// Config describes where and how to log, an empty File logs to stderr.
+type Config struct {
+	Level      string
+	Format     string
+	File       string
+	MaxSizeMB  int
+	MaxBackups int
+}
+
 // Initialize make a new Logger by given level
 func Initialize(level string) (*zap.SugaredLogger, error) {
+	return InitializeWithConfig(Config{Level: level, Format: FormatJSON})
+}
+
+// InitializeWithConfig make a new Logger, secrets are redacted from all entries.
+// Format auto is console for a terminal and json otherwise.
+func InitializeWithConfig(cfg Config) (*zap.SugaredLogger, error) {
+	var out zapcore.WriteSyncer = zapcore.Lock(os.Stderr)
+	isTerminal := term.IsTerminal(int(os.Stderr.Fd()))
+	if cfg.File != "" {
+		file, err := newRotatingFile(cfg.File, int64(cfg.MaxSizeMB)*1024*1024, cfg.MaxBackups)
+		if err != nil {
+			return nil, err
+		}
+		out = file
+		isTerminal = false
+	}
+
+	format := cfg.Format
+	if format == "" || format == FormatAuto {
+		format = FormatJSON
+		if isTerminal {
+			format = FormatConsole
+		}
+	}
 
-	cfg := zap.NewProductionConfig()
-	cfg.EncoderConfig.TimeKey = "@timestamp"
-	cfg.EncoderConfig.MessageKey = "message"
-	cfg.EncoderConfig.CallerKey = ""
-	cfg.EncoderConfig.EncodeTime = zapcore.ISO8601TimeEncoder
-	cfg.Level = zapLogLevel(level)
+	encoderConfig := zap.NewProductionEncoderConfig()
+	encoderConfig.TimeKey = "@timestamp"
+	encoderConfig.MessageKey = "message"
+	encoderConfig.CallerKey = ""
+	encoderConfig.EncodeTime = zapcore.ISO8601TimeEncoder
 
-	logger, err := cfg.Build()
-	if err != nil {
-		return nil, err
+	var encoder zapcore.Encoder
+	switch format {
+	case FormatJSON:
+		encoder = zapcore.NewJSONEncoder(encoderConfig)
+	case FormatConsole:
+		encoderConfig.EncodeTime = zapcore.TimeEncoderOfLayout("15:04:05.000")
+		encoderConfig.EncodeLevel = zapcore.CapitalLevelEncoder
+		if isTerminal {
+			encoderConfig.EncodeLevel = zapcore.CapitalColorLevelEncoder
+		}
+		encoder = zapcore.NewConsoleEncoder(encoderConfig)
+	default:
+		return nil, fmt.Errorf("unknown log format %s", cfg.Format)
 	}
 
-	zapLogger = logger.Sugar()
+	core := newRedactCore(zapcore.NewCore(encoder, out, zapLogLevel(cfg.Level)))
+	zapLogger = zap.New(core).Sugar()
 	return zapLogger, nil
 }
 
@@ -0,0 +1,127 @@
+package logger
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"regexp"
+	"strings"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+const Redacted = "[REDACTED]"
+
+// sensitiveKeys are field names and json keys whose values are never logged, compared lower case.
+var sensitiveKeys = []string{"value", "passframe", "publickey", "operatorpublickey", "privatekey", "token", "jwt", "creatorverification", "password", "passphrase", "secret", "authorization", "cookie", "set-cookie"}
+
+var (
+	pemPrivateKeyRegex = regexp.MustCompile(`-----BEGIN [A-Z ]*PRIVATE KEY-----[\s\S]*?-----END [A-Z ]*PRIVATE KEY-----`)
+	// base64 of "-----BEGIN", the format of the helper library
+	b64PemRegex = regexp.MustCompile(`LS0tLS1CRUdJTi[A-Za-z0-9+/]+=*`)
+	jwtRegex    = regexp.MustCompile(`eyJ[A-Za-z0-9+/_-]+=*\.[A-Za-z0-9+/_-]+=*\.[A-Za-z0-9+/_-]+=*`)
+)
+
+// IsSensitiveKey reports whether values of a field or json key with this name have to be redacted.
+func IsSensitiveKey(key string) bool {
+	key = strings.ToLower(key)
+	for _, s := range sensitiveKeys {
+		if key == s {
+			return true
+		}
+	}
+	return false
+}
+
+// RedactString scrubs private keys in pem or base64 pem format and JWTs from text.
+func RedactString(text string) string {
+	text = pemPrivateKeyRegex.ReplaceAllString(text, Redacted)
+	text = b64PemRegex.ReplaceAllStringFunc(text, func(match string) string {
+		decoded, err := base64.StdEncoding.DecodeString(match)
+		if err != nil || strings.Contains(string(decoded), "PRIVATE KEY") {
+			return Redacted
+		}
+		return match
+	})
+	return jwtRegex.ReplaceAllString(text, Redacted)
+}
+
+// RedactAny redacts sensitive keys of decoded json and all strings in it.
+func RedactAny(doc any) any {
+	switch v := doc.(type) {
+	case map[string]any:
+		for key, child := range v {
+			if IsSensitiveKey(key) {
+				v[key] = Redacted
+			} else {
+				v[key] = RedactAny(child)
+			}
+		}
+	case []any:
+		for i, child := range v {
+			v[i] = RedactAny(child)
+		}
+	case string:
+		return RedactString(v)
+	}
+	return doc
+}
+
+// redactCore scrubs secrets from messages and fields before the wrapped core writes them.
+type redactCore struct {
+	zapcore.Core
+}
+
+func newRedactCore(core zapcore.Core) zapcore.Core {
+	return &redactCore{Core: core}
+}
+
+func (c *redactCore) With(fields []zapcore.Field) zapcore.Core {
+	return &redactCore{Core: c.Core.With(redactFields(fields))}
+}
+
+func (c *redactCore) Check(entry zapcore.Entry, checked *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(entry.Level) {
+		return checked.AddCore(entry, c)
+	}
+	return checked
+}
+
+func (c *redactCore) Write(entry zapcore.Entry, fields []zapcore.Field) error {
+	entry.Message = RedactString(entry.Message)
+	return c.Core.Write(entry, redactFields(fields))
+}
+
+func redactFields(fields []zapcore.Field) []zapcore.Field {
+	result := make([]zapcore.Field, len(fields))
+	for i, field := range fields {
+		result[i] = redactField(field)
+	}
+	return result
+}
+
+func redactField(field zapcore.Field) zapcore.Field {
+	if IsSensitiveKey(field.Key) {
+		return zap.String(field.Key, Redacted)
+	}
+	switch field.Type {
+	case zapcore.StringType:
+		return zap.String(field.Key, RedactString(field.String))
+	case zapcore.ErrorType:
+		if err, ok := field.Interface.(error); ok {
+			return zap.String(field.Key, RedactString(err.Error()))
+		}
+	case zapcore.StringerType, zapcore.ReflectType, zapcore.ObjectMarshalerType, zapcore.ArrayMarshalerType:
+		// everything complex is passed through json to reach all nested strings
+		content, err := json.Marshal(field.Interface)
+		if err != nil {
+			return zap.String(field.Key, Redacted)
+		}
+		var doc any
+		if err := json.Unmarshal(content, &doc); err != nil {
+			return zap.String(field.Key, Redacted)
+		}
+		return zap.Any(field.Key, RedactAny(doc))
+	}
+	return field
+}
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/cryptvault-cloud/helper"
+	"github.com/urfave/cli/v2"
+)
+
+// operatorRights are granted to an identity which takes over the operator role.
+var operatorRights = []string{"(rwd)VALUES.>", "(rwd)IDENTITY.>", "(rwd)SYSTEM.>"}
+
+func GetOperatorCommand(pRunner *ProtectedRunner) *cli.Command {
+	nameFlag := &cli.StringFlag{
+		Name:  CliOperatorName,
+		Usage: "Name of the new operator identity",
+		Value: OperatorIdentityName,
+	}
+	return &cli.Command{
+		Name:  "operator",
+		Usage: "Rotate or hand over the operator key of a vault",
+		Subcommands: []*cli.Command{
+			{
+				Name:        "rotate",
+				Usage:       "Create a new operator key and sync all values to it",
+				Description: "The new key is saved at <vault>/operator, the old operator key is kept at <vault>/operator/previous until it is removed.",
+				Action:      pRunner.audited(pRunner.RotateOperator),
+				Flags:       []cli.Flag{nameFlag},
+			},
+			{
+				Name:        "transfer",
+				Usage:       "Hand over the vault to the owner of a public key",
+				Description: "The public key gets all rights and all values are synced to it. No private key will be saved locally.",
+				Action:      pRunner.audited(pRunner.TransferOperator),
+				Flags: []cli.Flag{
+					nameFlag,
+					&cli.StringFlag{
+						Name:     CliOperatorPublicKey,
+						EnvVars:  []string{getFlagEnvByFlagName(CliOperatorPublicKey)},
+						Usage:    "Public key of the new owner as b64, pem or OpenSSH authorized key",
+						Required: true,
+					},
+				},
+			},
+		},
+	}
+}
+
+// currentOperatorId returns the id of the operator key of the workspace or if not exists the id of the used key.
+func (r *ProtectedRunner) currentOperatorId(vaultName string) (string, error) {
+	b64PubKey, err := r.runner.fileHandler.ReadTextFile(fmt.Sprintf("%s/operator/key.pub", vaultName))
+	if err != nil {
+		return identityIdOfKey(&r.privateKey.PublicKey, *r.vaultId)
+	}
+	return helper.Base64PublicPem(strings.TrimSpace(b64PubKey)).GetIdentityId(*r.vaultId)
+}
+
+// addOperatorIdentity register the public key with all rights and sync all values to it.
+func (r *ProtectedRunner) addOperatorIdentity(name string, b64PubKey string) (string, error) {
+	pubKey, err := parsePublicKey(b64PubKey)
+	if err != nil {
+		return "", err
+	}
+	rights, err := getRightInputs(operatorRights)
+	if err != nil {
+		return "", err
+	}
+	res, err := r.api.AddIdentity(name, pubKey, rights)
+	if err != nil {
+		return "", err
+	}
+	err = r.api.SyncValues(res.IdentityId)
+	if err != nil {
+		if err2 := r.api.DeleteIdentity(res.IdentityId); err2 != nil {
+			return "", errors.Join(err, fmt.Errorf("failed to rollback new identity %s: %w", res.IdentityId, err2))
+		}
+		return "", err
+	}
+	return res.IdentityId, nil
+}
+
+func (r *ProtectedRunner) RotateOperator(c *cli.Context) error {
+	vaultName, err := r.runner.fileHandler.SelectedVault()
+	if err != nil {
+		return err
+	}
+	oldId, err := r.currentOperatorId(vaultName)
+	if err != nil {
+		return err
+	}
+	privKey, pubKey, err := r.runner.api.GetNewIdentityKeyPair()
+	if err != nil {
+		return err
+	}
+	b64PubKey, err := helper.GetB64FromPublicKey(pubKey)
+	if err != nil {
+		return err
+	}
+	b64PrivKey, err := helper.GetB64FromPrivateKey(privKey)
+	if err != nil {
+		return err
+	}
+	// the old key is saved before anything is changed, so it can not get lost if a later step fails
+	oldFiles := make(map[string]string)
+	for _, file := range []string{"key", "key.pub", "id"} {
+		content, err := r.runner.fileHandler.ReadTextFile(fmt.Sprintf("%s/operator/%s", vaultName, file))
+		if err == nil {
+			oldFiles[file] = content
+		}
+	}
+	for _, file := range []string{"key", "key.pub"} {
+		content, ok := oldFiles[file]
+		if !ok {
+			continue
+		}
+		err = r.runner.fileHandler.SaveTextToFile(fmt.Sprintf("%s/operator/previous/%s", vaultName, file), content)
+		if err != nil {
+			return fmt.Errorf("failed to save old operator key to previous, nothing was changed: %w", err)
+		}
+	}
+
+	newId, err := r.addOperatorIdentity(c.String(CliOperatorName), b64PubKey)
+	if err != nil {
+		return err
+	}
+
+	newFiles := map[string]string{
+		"key.pub": b64PubKey,
+		"key":     b64PrivKey,
+		"id":      newId,
+	}
+	for file, content := range newFiles {
+		err = errors.Join(r.runner.fileHandler.SaveTextToFile(fmt.Sprintf("%s/operator/%s", vaultName, file), content), err)
+	}
+	if err != nil {
+		// restore the old local key so it still matches the old operator identity
+		for file := range newFiles {
+			filePath := fmt.Sprintf("%s/operator/%s", vaultName, file)
+			if content, ok := oldFiles[file]; ok {
+				err = errors.Join(r.runner.fileHandler.SaveTextToFile(filePath, content), err)
+			} else {
+				err = errors.Join(r.runner.fileHandler.DeleteFolder(filePath), err)
+			}
+		}
+		if err2 := r.api.DeleteIdentity(newId); err2 != nil {
+			return errors.Join(err, fmt.Errorf("failed to rollback new identity %s: %w", newId, err2))
+		}
+		return errors.Join(err, fmt.Errorf("new operator identity was removed again, %s is unchanged", oldId))
+	}
+
+	fmt.Printf("New operator identity %s was created and saved at %s\n", newId, r.runner.fileHandler.FullPath(fmt.Sprintf("%s/operator", vaultName)))
+	fmt.Printf("Old operator key was moved to %s\n", r.runner.fileHandler.FullPath(fmt.Sprintf("%s/operator/previous", vaultName)))
+	printOperatorChecklist(oldId, newId)
+	return nil
+}
+
+func (r *ProtectedRunner) TransferOperator(c *cli.Context) error {
+	vaultName, err := r.runner.fileHandler.SelectedVault()
+	if err != nil {
+		return err
+	}
+	oldId, err := r.currentOperatorId(vaultName)
+	if err != nil {
+		return err
+	}
+	pubKey, err := parsePublicKey(c.String(CliOperatorPublicKey))
+	if err != nil {
+		return err
+	}
+	b64PubKey, err := helper.GetB64FromPublicKey(pubKey)
+	if err != nil {
+		return err
+	}
+	newId, err := r.addOperatorIdentity(c.String(CliOperatorName), b64PubKey)
+	if err != nil {
+		return err
+	}
+	err = errors.Join(r.runner.fileHandler.SaveTextToFile(fmt.Sprintf("%s/operator/transferred/key.pub", vaultName), b64PubKey), err)
+	err = errors.Join(r.runner.fileHandler.SaveTextToFile(fmt.Sprintf("%s/operator/transferred/id", vaultName), newId), err)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Vault was handed over to identity %s\n", newId)
+	fmt.Printf("Public key and id of the new owner were saved at %s\n", r.runner.fileHandler.FullPath(fmt.Sprintf("%s/operator/transferred", vaultName)))
+	printOperatorChecklist(oldId, newId)
+	return nil
+}
+
+func printOperatorChecklist(oldId, newId string) {
+	fmt.Print("\nUntil the old operator identity is removed the old key can still:\n")
+	fmt.Print("\t- read every value that is encrypted for it\n")
+	fmt.Print("\t- create, update and delete values and identities\n")
+	fmt.Print("\t- delete the vault\n")
+	fmt.Print("\t- act as root of the signature chain of identities it has created\n")
+	fmt.Print("\nChecklist:\n")
+	fmt.Printf("\t[ ] verify the new key works: vault-cli protected --creds <new key> get identity --id %s\n", newId)
+	fmt.Print("\t[ ] move identities created by the old key to the new key (rotate or re-add them)\n")
+	fmt.Printf("\t[ ] remove the old operator identity with the new key: vault-cli protected --creds <new key> delete identity --id %s\n", oldId)
+	fmt.Print("\t[ ] destroy every copy of the old operator key\n")
+}
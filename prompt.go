@@ -0,0 +1,88 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/urfave/cli/v2"
+	"golang.org/x/term"
+)
+
+var stdinReader = bufio.NewReader(os.Stdin)
+
+// isTerminal reports whether f is an interactive terminal.
+func isTerminal(f *os.File) bool {
+	return term.IsTerminal(int(f.Fd()))
+}
+
+// confirm asks a yes/no question at stdin, an empty answer returns defaultYes.
+func confirm(question string, defaultYes bool) (bool, error) {
+	choices := "[y/N]"
+	if defaultYes {
+		choices = "[Y/n]"
+	}
+	fmt.Printf("%s %s ", question, choices)
+	answer, err := stdinReader.ReadString('\n')
+	if err != nil {
+		return false, err
+	}
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "":
+		return defaultYes, nil
+	case "y", "yes":
+		return true, nil
+	}
+	return false, nil
+}
+
+// confirmDestructive asks before something is deleted, skipped with yes or at dry-run.
+// It refuses if stdin is not a terminal, so scripts have to pass --yes explicit.
+func confirmDestructive(c *cli.Context, question string) error {
+	if c.Bool(CliDeleteYes) || isDryRun(c) {
+		return nil
+	}
+	if !isTerminal(os.Stdin) {
+		return withExitCode(ExitUsage, fmt.Errorf("stdin is not a terminal, use --%s to delete without asking", CliDeleteYes))
+	}
+	ok, err := confirm(question, false)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("aborted")
+	}
+	return nil
+}
+
+// readPassphrase returns the passphrase flag or asks for it at the terminal, so it does not end up in the shell history.
+// With confirmTwice it has to be typed twice, used where a typo would make the written file unreadable.
+func readPassphrase(c *cli.Context, confirmTwice bool) (string, error) {
+	if passphrase := c.String(CliBackupPassphrase); passphrase != "" {
+		return passphrase, nil
+	}
+	if !isTerminal(os.Stdin) {
+		return "", withExitCode(ExitUsage, fmt.Errorf("stdin is not a terminal, set --%s or %s", CliBackupPassphrase, getFlagEnvByFlagName(CliBackupPassphrase)))
+	}
+	read := func(prompt string) (string, error) {
+		fmt.Fprint(os.Stderr, prompt)
+		passphrase, err := term.ReadPassword(int(os.Stdin.Fd()))
+		fmt.Fprintln(os.Stderr)
+		return string(passphrase), err
+	}
+	passphrase, err := read("Passphrase: ")
+	if err != nil {
+		return "", err
+	}
+	if confirmTwice {
+		repeated, err := read("Repeat passphrase: ")
+		if err != nil {
+			return "", err
+		}
+		if repeated != passphrase {
+			return "", withExitCode(ExitUsage, fmt.Errorf("passphrases do not match"))
+		}
+	}
+	return passphrase, nil
+}
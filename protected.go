@@ -4,13 +4,16 @@ import (
 	"crypto/ecdsa"
 	"errors"
 	"fmt"
+	"io/fs"
 	"os"
 	"path"
 	"regexp"
 	"strings"
+	"syscall"
 
 	client "github.com/cryptvault-cloud/api"
 	"github.com/cryptvault-cloud/helper"
+	"github.com/cryptvault-cloud/vault-cli/logger"
 	"github.com/urfave/cli/v2"
 )
 
@@ -35,8 +38,29 @@ var AllValueType = []ValueType{
 
 var ValuePatternRegex *regexp.Regexp
 
+var ValueNameRegex *regexp.Regexp
+
 func init() {
 	ValuePatternRegex = regexp.MustCompile(helper.ValuePatternRegexStr)
+	ValueNameRegex = regexp.MustCompile(helper.ValuesPatternRegexStr)
+}
+
+// protectedFlags are the flags to select the private key and the vault of a ProtectedRunner.
+func protectedFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.StringFlag{
+			Name:     CliProtectedHandlerKey,
+			Aliases:  []string{"creds"},
+			EnvVars:  []string{getFlagEnvByFlagName(CliProtectedHandlerKey)},
+			Usage:    "Private key wich have rights to handle subcommand or path to private key, b64, pem or OpenSSH ECDSA ",
+			Required: true,
+		},
+		&cli.StringFlag{
+			Name:    CliProtectedVaultId,
+			EnvVars: []string{getFlagEnvByFlagName(CliProtectedVaultId)},
+			Usage:   "vaultid to handle subcommand",
+		},
+	}
 }
 
 func GetProtectedCommand(runner *Runner) *cli.Command {
@@ -46,20 +70,7 @@ func GetProtectedCommand(runner *Runner) *cli.Command {
 		Name:   "protected",
 		Usage:  "All stuff where you need a private key and a vault id to handle",
 		Before: pRunner.Before,
-		Flags: []cli.Flag{
-			&cli.StringFlag{
-				Name:     CliProtectedHandlerKey,
-				Aliases:  []string{"creds"},
-				EnvVars:  []string{getFlagEnvByFlagName(CliProtectedHandlerKey)},
-				Usage:    "Private key wich have rights to handle subcommand or path to private key ",
-				Required: true,
-			},
-			&cli.StringFlag{
-				Name:    CliProtectedVaultId,
-				EnvVars: []string{getFlagEnvByFlagName(CliProtectedVaultId)},
-				Usage:   "vaultid to handle subcommand",
-			},
-		},
+		Flags:  protectedFlags(),
 		Subcommands: []*cli.Command{
 			{
 				Name:  "add",
@@ -68,7 +79,7 @@ func GetProtectedCommand(runner *Runner) *cli.Command {
 					{
 						Name:   "identity",
 						Usage:  "add a new identity",
-						Action: pRunner.AddIdentity,
+						Action: pRunner.audited(pRunner.AddIdentity),
 						Flags: []cli.Flag{
 							&cli.StringFlag{
 								Name:     CliAddIdentityName,
@@ -79,7 +90,7 @@ func GetProtectedCommand(runner *Runner) *cli.Command {
 							&cli.StringFlag{
 								Name:     CliAddIdentityPublicKey,
 								EnvVars:  []string{getFlagEnvByFlagName(CliAddIdentityPublicKey)},
-								Usage:    "If set, no new key pair will created, it will use this public key as identity base",
+								Usage:    "If set, no new key pair will created, it will use this public key (b64, pem or OpenSSH) as identity base",
 								Value:    "",
 								Required: false,
 							},
@@ -122,7 +133,7 @@ func GetProtectedCommand(runner *Runner) *cli.Command {
 								Value:   "String",
 							},
 						},
-						Action: pRunner.AddValue,
+						Action: pRunner.audited(pRunner.AddValue),
 					},
 				},
 			},
@@ -147,14 +158,14 @@ func GetProtectedCommand(runner *Runner) *cli.Command {
 						Name:   "value",
 						Usage:  "returns the secret",
 						Action: pRunner.GetValue,
-						Flags: []cli.Flag{
+						Flags: append([]cli.Flag{
 							&cli.StringFlag{
 								Name:     CliGetValueName,
 								EnvVars:  []string{getFlagEnvByFlagName(CliGetValueName)},
 								Usage:    "Value name something like VALUES.a.b",
 								Required: true,
 							},
-						},
+						}, valueCacheFlags()...),
 					},
 				},
 			},
@@ -182,7 +193,7 @@ func GetProtectedCommand(runner *Runner) *cli.Command {
 					{
 						Name:   "value",
 						Usage:  "update a value and set new secret",
-						Action: pRunner.UpdateValue,
+						Action: pRunner.audited(pRunner.UpdateValue),
 						Flags: []cli.Flag{
 							&cli.StringFlag{
 								Name:     CliUpdateValueName,
@@ -207,7 +218,7 @@ func GetProtectedCommand(runner *Runner) *cli.Command {
 					{
 						Name:   "identity",
 						Usage:  "update a identity",
-						Action: pRunner.UpdateIdentity,
+						Action: pRunner.audited(pRunner.UpdateIdentity),
 						Flags: []cli.Flag{
 							&cli.StringFlag{
 								Name:     CliUpdateIdentityId,
@@ -259,14 +270,16 @@ func GetProtectedCommand(runner *Runner) *cli.Command {
 				Usage: "Get Secrets, Identity",
 				Subcommands: []*cli.Command{
 					{
-						Name:   "vault",
-						Usage:  "Delete an empty vault",
-						Action: pRunner.DeleteVault,
+						Name:        "vault",
+						Usage:       "Delete a vault",
+						Description: "Only an empty vault can be deleted, use --inventory to see what is left and --purge to delete it too.",
+						Action:      pRunner.audited(pRunner.DeleteVault),
+						Flags:       deleteVaultFlags(),
 					},
 					{
 						Name:   "identity",
 						Usage:  "Delete an identity",
-						Action: pRunner.DeleteIdentity,
+						Action: pRunner.audited(pRunner.DeleteIdentity),
 						Flags: []cli.Flag{
 							&cli.StringFlag{
 								Name:     CliDeleteIdentityId,
@@ -274,12 +287,16 @@ func GetProtectedCommand(runner *Runner) *cli.Command {
 								Usage:    "ID of identity",
 								Required: true,
 							},
+							&cli.BoolFlag{
+								Name:  CliDeleteYes,
+								Usage: "Delete without asking",
+							},
 						},
 					},
 					{
 						Name:   "value",
 						Usage:  "Delete an value",
-						Action: pRunner.DeleteValue,
+						Action: pRunner.audited(pRunner.DeleteValue),
 						Flags: []cli.Flag{
 							&cli.StringFlag{
 								Name:     CliDeleteValueName,
@@ -287,10 +304,21 @@ func GetProtectedCommand(runner *Runner) *cli.Command {
 								Usage:    "Name of value to delete",
 								Required: true,
 							},
+							&cli.BoolFlag{
+								Name:  CliDeleteYes,
+								Usage: "Delete without asking",
+							},
 						},
 					},
 				},
 			},
+			GetDiffCommand(pRunner),
+			GetSnapshotCommand(pRunner),
+			GetWatchCommand(pRunner),
+			GetK8sCommand(pRunner),
+			GetRotateCommand(pRunner),
+			GetOperatorCommand(pRunner),
+			GetApproveCommand(pRunner),
 			{
 				Name:   "authToken",
 				Usage:  "Generate JWT-Authtoken",
@@ -303,9 +331,11 @@ func GetProtectedCommand(runner *Runner) *cli.Command {
 func (r *ProtectedRunner) Before(c *cli.Context) error {
 	pemKeyOrPath := c.String(CliProtectedHandlerKey)
 	pemKey := ""
-	if _, err := os.Stat(pemKeyOrPath); errors.Is(err, os.ErrNotExist) {
-		// path does not exist so it have to be private key directly
+	if _, err := os.Stat(pemKeyOrPath); errors.Is(err, fs.ErrNotExist) || errors.Is(err, syscall.ENAMETOOLONG) {
+		// path does not exist or is no valid path at all, so it have to be private key directly
 		pemKey = pemKeyOrPath
+	} else if err != nil {
+		return withExitCode(ExitWorkspace, fmt.Errorf("key file %s can not be read: %w", pemKeyOrPath, err))
 	} else {
 		t, err := r.runner.fileHandler.ReadTextFile(pemKeyOrPath)
 		if err != nil {
@@ -313,7 +343,7 @@ func (r *ProtectedRunner) Before(c *cli.Context) error {
 		}
 		pemKey = t
 	}
-	privKey, err := helper.GetPrivateKeyFromB64String(pemKey)
+	privKey, err := parsePrivateKey(pemKey)
 	if err != nil {
 		return err
 	}
@@ -330,21 +360,27 @@ func (r *ProtectedRunner) Before(c *cli.Context) error {
 	}
 	r.privateKey = privKey
 	r.vaultId = &vaultId
+	if identityId, err := identityIdOfKey(&privKey.PublicKey, vaultId); err == nil {
+		logger.GetWithStructAndFunc("ProtectedRunner", "Before").Debugw("use identity", "vaultId", vaultId, "identityId", identityId)
+	}
 
 	r.api = r.runner.api.GetProtectedApi(privKey, vaultId)
 	return nil
 }
 func (r *ProtectedRunner) ListAllIdentities(c *cli.Context) error {
-
-	identityResult, err := r.api.GetAllIdentities()
+	// the identities query of the api library has no id and public key, both are needed to show the right fingerprint
+	identities, err := r.queryIdentities(c)
 	if err != nil {
 		return err
 	}
-	identities := identityResult.QueryIdentity.Data
 	for _, identity := range identities {
-		fmt.Printf("%s\n", *identity.Name)
+		name := ""
+		if identity.Name != nil {
+			name = *identity.Name
+		}
+		fmt.Printf("%s\t%s\t%s\n", name, identity.Id, identity.fingerprint())
 		for _, right := range identity.Rights {
-			fmt.Printf("\t(%s)%s\n", right.Right[:1], right.RightValuePattern)
+			fmt.Printf("\t%s\n", rightString(right.Right, right.RightValuePattern))
 		}
 	}
 	return nil
@@ -405,6 +441,11 @@ func (r *ProtectedRunner) UpdateValue(c *cli.Context) error {
 	return nil
 }
 
+// rightString formats a right like it is given at the command line, something like (r)VALUES.a.>
+func rightString(right client.Directions, pattern string) string {
+	return fmt.Sprintf("(%s)%s", right[:1], pattern)
+}
+
 func getRightInputs(rights []string) ([]*client.RightInput, error) {
 	rightInputs := make([]*client.RightInput, 0)
 	var errs error = nil
@@ -562,12 +603,13 @@ func (r *ProtectedRunner) AddIdentity(c *cli.Context) error {
 			return err
 		}
 		fmt.Print("Identity was created \n")
+		fmt.Printf("Fingerprint: %s\n", fingerprintOrUnknown(res.PublicKey))
 		fmt.Printf("Identity information was saved at %s\n", path.Join(c.String(CliSaveFilePath), vaultName, "identity", name))
 		return nil
 	} else {
 
 		// add identity by given public key
-		pubKey, err := helper.GetPublicKeyFromB64String(b64pubKey)
+		pubKey, err := parsePublicKey(b64pubKey)
 		if err != nil {
 			return err
 		}
@@ -576,7 +618,7 @@ func (r *ProtectedRunner) AddIdentity(c *cli.Context) error {
 		if err != nil {
 			return err
 		}
-		fmt.Print("Identity was created\nNo Information will be saved locally...")
+		fmt.Printf("Identity was created\nFingerprint: %s\nNo Information will be saved locally...", fingerprintOrUnknown(pubKey))
 		return nil
 	}
 
@@ -595,26 +637,38 @@ func (r *ProtectedRunner) GetIdentity(c *cli.Context) error {
 		rigthstr[i] = fmt.Sprintf("(%s)%s", v.Right[:1], v.RightValuePattern)
 	}
 
-	fmt.Printf("ID: %s\nName: %s\nRights: \n\t%s\n", res.Id, *res.Name, strings.Join(rigthstr, "\n\t"))
+	fingerprint := "unknown"
+	if pubKey, err := res.PublicKey.GetPublicKey(); err == nil {
+		fingerprint = fingerprintOrUnknown(pubKey)
+	}
+
+	fmt.Printf("ID: %s\nName: %s\nFingerprint: %s\nRights: \n\t%s\n", res.Id, *res.Name, fingerprint, strings.Join(rigthstr, "\n\t"))
 	return nil
 }
 
 func (r *ProtectedRunner) GetValue(c *cli.Context) error {
 	name := c.String(CliGetValueName)
-	value, err := r.api.GetValueByName(name)
+	passframe, err := r.valueWithCache(c, name, func() (string, error) {
+		return r.fetchValue(name)
+	})
 	if err != nil {
 		return err
 	}
+	fmt.Println(passframe)
+	return nil
+}
+
+// fetchValue returns the decrypted value with name from the server.
+func (r *ProtectedRunner) fetchValue(name string) (string, error) {
+	value, err := r.api.GetValueByName(name)
+	if err != nil {
+		return "", err
+	}
 	values := make([]client.EncryptenValue, 0)
 	for _, v := range value.GetValue() {
 		values = append(values, v)
 	}
-	passframe, err := r.api.GetDecryptedPassframe(values)
-	if err != nil {
-		return err
-	}
-	fmt.Println(passframe)
-	return nil
+	return r.api.GetDecryptedPassframe(values)
 }
 
 func (r *ProtectedRunner) GenerateAuthToken(c *cli.Context) error {
@@ -626,17 +680,6 @@ func (r *ProtectedRunner) GenerateAuthToken(c *cli.Context) error {
 	return nil
 }
 
-func (r *ProtectedRunner) DeleteVault(c *cli.Context) error {
-
-	err := r.api.DeleteVault(*r.vaultId)
-	if err != nil {
-		return err
-	}
-	fmt.Println("Vault Deleted")
-	return nil
-
-}
-
 func (r *ProtectedRunner) DeleteIdentity(c *cli.Context) error {
 	vaultName, err := r.runner.fileHandler.SelectedVault()
 	if err != nil {
@@ -648,15 +691,35 @@ func (r *ProtectedRunner) DeleteIdentity(c *cli.Context) error {
 	if err != nil {
 		return err
 	}
+	values, err := r.api.GetAllRelatedValues(identityIdToDelete)
+	if err != nil {
+		return err
+	}
+	rightStrs := make([]string, len(res.Rights))
+	for i, v := range res.Rights {
+		rightStrs[i] = rightString(v.Right, v.RightValuePattern)
+	}
+	localFolder := fmt.Sprintf("%s/identity/%s", vaultName, *res.Name)
+	fmt.Printf("Identity to delete:\nID: %s\nName: %s\nRights: \n\t%s\nValues readable by this identity: %d\n", res.Id, *res.Name, strings.Join(rightStrs, "\n\t"), len(values))
+	if _, err := r.runner.identityKey(vaultName, *res.Name); err == nil {
+		fmt.Printf("Local key at %s will be moved to the trash\n", r.runner.fileHandler.FullPath(localFolder))
+	}
+	if err := confirmDestructive(c, fmt.Sprintf("Delete identity %s?", *res.Name)); err != nil {
+		return err
+	}
+
 	err = r.api.DeleteIdentity(identityIdToDelete)
 	if err != nil {
 		return err
 	}
-	err = r.runner.fileHandler.DeleteFolder(fmt.Sprintf("%s/identity/%s", vaultName, *res.Name))
+	trashId, err := r.runner.moveToTrash(localFolder)
 	if err != nil {
 		return err
 	}
 	fmt.Println("Identity Deleted")
+	if trashId != "" {
+		fmt.Printf("Local key was moved to the trash, restore it with: vault-cli local trash restore --id %s\n", trashId)
+	}
 	return nil
 }
 
@@ -666,6 +729,10 @@ func (r *ProtectedRunner) DeleteValue(c *cli.Context) error {
 	if err != nil {
 		return err
 	}
+	fmt.Printf("Value to delete:\nID: %s\nName: %s\nType: %s\nIdentities with access: %d\n", value.Id, value.Name, value.Type, len(value.Value))
+	if err := confirmDestructive(c, fmt.Sprintf("Delete value %s?", value.Name)); err != nil {
+		return err
+	}
 	err = r.api.DeleteValue(value.Id)
 	if err != nil {
 		return err
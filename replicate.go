@@ -0,0 +1,203 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	client "github.com/cryptvault-cloud/api"
+	"github.com/cryptvault-cloud/helper"
+	"github.com/urfave/cli/v2"
+)
+
+type ReplicateRunner struct {
+	runner *Runner
+	// destination vault and identity, set once resolved for the audit log
+	dstVaultId    string
+	dstIdentityId string
+}
+
+type replicateAction string
+
+const (
+	replicateCreate    replicateAction = "created"
+	replicateUpdate    replicateAction = "updated"
+	replicateUnchanged replicateAction = "unchanged"
+	replicateFailed    replicateAction = "failed"
+)
+
+type valueNameMapping struct {
+	from string
+	to   string
+}
+
+func GetReplicateCommand(runner *Runner) *cli.Command {
+	rRunner := &ReplicateRunner{runner: runner}
+	return &cli.Command{
+		Name:        "replicate",
+		Usage:       "Copy values from one local vault to another",
+		Description: "Values are read with an identity of the source vault and re-encrypted for the destination vault by an identity of the destination vault.",
+		Action:      rRunner.audited(rRunner.Replicate),
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:     CliReplicateFromVault,
+				Usage:    "Name of the local vault to read values from",
+				Required: true,
+			},
+			&cli.StringFlag{
+				Name:     CliReplicateToVault,
+				Usage:    "Name of the local vault to write values to",
+				Required: true,
+			},
+			&cli.StringFlag{
+				Name:  CliReplicateFromIdentity,
+				Usage: "Local identity of the source vault, use operator for the operator key",
+				Value: OperatorIdentityName,
+			},
+			&cli.StringFlag{
+				Name:  CliReplicateToIdentity,
+				Usage: "Local identity of the destination vault, use operator for the operator key",
+				Value: OperatorIdentityName,
+			},
+			&cli.StringFlag{
+				Name:  CliReplicatePrefix,
+				Usage: "Pattern of values to replicate something like VALUES.shared.>",
+				Value: "VALUES.>",
+			},
+			&cli.StringSliceFlag{
+				Name:  CliReplicateMap,
+				Usage: "Rename values at destination, something like VALUES.shared=VALUES.common",
+			},
+			&cli.StringSliceFlag{
+				Name:  CliReplicateInclude,
+				Usage: "Only replicate values matching one of this patterns",
+			},
+			&cli.StringSliceFlag{
+				Name:  CliReplicateExclude,
+				Usage: "Skip values matching one of this patterns",
+			},
+			&cli.BoolFlag{
+				Name:  CliDryRun,
+				Usage: "Only show what would be changed, same as the global --dry-run",
+			},
+		},
+	}
+}
+
+func parseValueNameMappings(mappings []string) ([]valueNameMapping, error) {
+	result := make([]valueNameMapping, 0, len(mappings))
+	for _, m := range mappings {
+		from, to, found := strings.Cut(m, "=")
+		if !found || !ValueNameRegex.MatchString(from) || !ValueNameRegex.MatchString(to) {
+			return nil, fmt.Errorf("invalid mapping %s, expected something like VALUES.a=VALUES.b", m)
+		}
+		result = append(result, valueNameMapping{from: from, to: to})
+	}
+	return result, nil
+}
+
+// mapValueName renames name by the first mapping which is a token prefix of name.
+func mapValueName(name string, mappings []valueNameMapping) string {
+	for _, m := range mappings {
+		if name == m.from {
+			return m.to
+		}
+		if strings.HasPrefix(name, m.from+".") {
+			return m.to + strings.TrimPrefix(name, m.from)
+		}
+	}
+	return name
+}
+
+func matchAnyValueName(patterns []string, name string) bool {
+	return helper.Includes(patterns, func(p string) bool { return matchValueName(p, name) })
+}
+
+func (r *ReplicateRunner) Replicate(c *cli.Context) error {
+	prefix := c.String(CliReplicatePrefix)
+	includes := c.StringSlice(CliReplicateInclude)
+	excludes := c.StringSlice(CliReplicateExclude)
+	dryRun := isDryRun(c)
+	mappings, err := parseValueNameMappings(c.StringSlice(CliReplicateMap))
+	if err != nil {
+		return err
+	}
+
+	srcApi, _, srcIdentityId, err := r.runner.protectedApiByWorkspace(c.String(CliReplicateFromVault), c.String(CliReplicateFromIdentity))
+	if err != nil {
+		return err
+	}
+	dstApi, dstVaultId, dstIdentityId, err := r.runner.protectedApiByWorkspace(c.String(CliReplicateToVault), c.String(CliReplicateToIdentity))
+	if err != nil {
+		return err
+	}
+	r.dstVaultId, r.dstIdentityId = dstVaultId, dstIdentityId
+
+	srcValues, err := srcApi.GetAllRelatedValues(srcIdentityId)
+	if err != nil {
+		return err
+	}
+	dstValues, err := dstApi.GetAllRelatedValues(dstIdentityId)
+	if err != nil {
+		return err
+	}
+	dstIds := make(map[string]string, len(dstValues))
+	for _, v := range dstValues {
+		dstIds[v.Name] = v.Id
+	}
+
+	summary := make(map[replicateAction]int)
+	var errs error = nil
+	for _, v := range srcValues {
+		if !matchValueName(prefix, v.Name) {
+			continue
+		}
+		if len(includes) > 0 && !matchAnyValueName(includes, v.Name) {
+			continue
+		}
+		if matchAnyValueName(excludes, v.Name) {
+			continue
+		}
+		target := mapValueName(v.Name, mappings)
+		action, err := replicateValue(srcApi, dstApi, v.Id, target, dstIds[target], dryRun)
+		if err != nil {
+			errs = errors.Join(errs, fmt.Errorf("%s: %w", v.Name, err))
+		}
+		summary[action]++
+		fmt.Printf("%-9s %s -> %s\n", action, v.Name, target)
+	}
+
+	if dryRun {
+		fmt.Print("Dry run, nothing was changed\n")
+	}
+	fmt.Printf("Summary: %d created, %d updated, %d unchanged, %d failed\n", summary[replicateCreate], summary[replicateUpdate], summary[replicateUnchanged], summary[replicateFailed])
+	return errs
+}
+
+func replicateValue(srcApi, dstApi client.ProtectedApiHandler, srcId, target, dstId string, dryRun bool) (replicateAction, error) {
+	value, err := srcApi.GetIdentityValueById(srcId)
+	if err != nil {
+		return replicateFailed, err
+	}
+	if dstId == "" {
+		if !dryRun {
+			if _, err := dstApi.AddValue(target, value.Value, value.Type); err != nil {
+				return replicateFailed, err
+			}
+		}
+		return replicateCreate, nil
+	}
+	current, err := dstApi.GetIdentityValueById(dstId)
+	if err != nil {
+		return replicateFailed, err
+	}
+	if current.Value == value.Value && current.Type == value.Type {
+		return replicateUnchanged, nil
+	}
+	if !dryRun {
+		if _, err := dstApi.UpdateValue(dstId, target, value.Value, value.Type); err != nil {
+			return replicateFailed, err
+		}
+	}
+	return replicateUpdate, nil
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/cryptvault-cloud/helper"
+	"github.com/urfave/cli/v2"
+)
+
+func GetRotateCommand(pRunner *ProtectedRunner) *cli.Command {
+	return &cli.Command{
+		Name:  "rotate",
+		Usage: "Rotate keys",
+		Subcommands: []*cli.Command{
+			{
+				Name:        "identity",
+				Usage:       "Replace the key pair of an identity",
+				Description: "A new identity with the same name and rights is created and all values are synced to it. The old identity is deleted after the sync was successful.",
+				Action:      pRunner.audited(pRunner.RotateIdentity),
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:     CliRotateIdentityId,
+						EnvVars:  []string{getFlagEnvByFlagName(CliRotateIdentityId)},
+						Usage:    "ID of identity to rotate",
+						Required: true,
+					},
+				},
+			},
+		},
+	}
+}
+
+func (r *ProtectedRunner) RotateIdentity(c *cli.Context) error {
+	oldId := c.String(CliRotateIdentityId)
+	identity, err := r.api.GetIdentity(oldId)
+	if err != nil {
+		return err
+	}
+	if identity == nil || identity.Name == nil {
+		return fmt.Errorf("identity %s not found", oldId)
+	}
+	name := *identity.Name
+
+	rightStr := make([]string, 0, len(identity.Rights))
+	for _, right := range identity.Rights {
+		rightStr = append(rightStr, rightString(right.Right, right.RightValuePattern))
+	}
+	rights, err := getRightInputs(rightStr)
+	if err != nil {
+		return err
+	}
+
+	privKey, pubKey, err := r.runner.api.GetNewIdentityKeyPair()
+	if err != nil {
+		return err
+	}
+	res, err := r.api.AddIdentity(name, pubKey, rights)
+	if err != nil {
+		return err
+	}
+	rollback := func(cause error) error {
+		if err := r.api.DeleteIdentity(res.IdentityId); err != nil {
+			return errors.Join(cause, fmt.Errorf("failed to rollback new identity %s: %w", res.IdentityId, err))
+		}
+		return errors.Join(cause, fmt.Errorf("new identity was removed again, %s is unchanged", oldId))
+	}
+
+	err = r.api.SyncValues(res.IdentityId)
+	if err != nil {
+		return rollback(err)
+	}
+
+	vaultName, err := r.runner.fileHandler.SelectedVault()
+	if err != nil {
+		return rollback(err)
+	}
+	b64PubKey, err := helper.GetB64FromPublicKey(pubKey)
+	if err != nil {
+		return rollback(err)
+	}
+	b64PrivKey, err := helper.GetB64FromPrivateKey(privKey)
+	if err != nil {
+		return rollback(err)
+	}
+	newFiles := map[string]string{
+		fmt.Sprintf("%s/identity/%s/key.pub", vaultName, name): b64PubKey,
+		fmt.Sprintf("%s/identity/%s/key", vaultName, name):     b64PrivKey,
+		fmt.Sprintf("%s/identity/%s/id", vaultName, name):      res.IdentityId,
+	}
+	oldFiles := make(map[string]string)
+	for filePath := range newFiles {
+		if content, err := r.runner.fileHandler.ReadTextFile(filePath); err == nil {
+			oldFiles[filePath] = content
+		}
+	}
+	for filePath, content := range newFiles {
+		err = errors.Join(r.runner.fileHandler.SaveTextToFile(filePath, content), err)
+	}
+	if err != nil {
+		// restore the old local key so it still matches the old identity
+		for filePath, content := range oldFiles {
+			err = errors.Join(r.runner.fileHandler.SaveTextToFile(filePath, content), err)
+		}
+		return rollback(err)
+	}
+
+	err = r.api.DeleteIdentity(oldId)
+	if err != nil {
+		return fmt.Errorf("new identity %s is ready but old identity %s could not be deleted: %w", res.IdentityId, oldId, err)
+	}
+	fmt.Printf("Identity %s was rotated\n", name)
+	fmt.Printf("Old ID: %s\nNew ID: %s\n", oldId, res.IdentityId)
+	fmt.Printf("Identity information was saved at %s\n", r.runner.fileHandler.FullPath(fmt.Sprintf("%s/identity/%s", vaultName, name)))
+	return nil
+}
@@ -0,0 +1,257 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/cryptvault-cloud/vault-cli/logger"
+	"github.com/urfave/cli/v2"
+)
+
+const serveValuesPath = "/v1/values/"
+
+// valueServer is a read-only http api for the values readable by one identity.
+type valueServer struct {
+	pRunner  *ProtectedRunner
+	prefixes []string
+	ttl      time.Duration
+	mu       sync.Mutex
+	cache    map[string]servedValue
+}
+
+type servedValue struct {
+	value   string
+	expires time.Time
+}
+
+// statusRecorder remembers the status code for the request log.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (s *statusRecorder) WriteHeader(status int) {
+	s.status = status
+	s.ResponseWriter.WriteHeader(status)
+}
+
+func GetServeCommand(runner *Runner) *cli.Command {
+	pRunner := &ProtectedRunner{runner: runner}
+	return &cli.Command{
+		Name:        "serve",
+		Usage:       "Serve values read-only over a local unix socket or tcp",
+		Description: "GET /v1/values/<name> returns the value as text, GET /v1/health returns ok. Only names below one of the --allow-prefix are served.",
+		Before:      pRunner.Before,
+		Action:      pRunner.Serve,
+		Flags: append(protectedFlags(),
+			&cli.StringFlag{
+				Name:    CliServeListen,
+				EnvVars: []string{getFlagEnvByFlagName("serve_" + CliServeListen)},
+				Usage:   "unix:///path/to/socket or tcp://127.0.0.1:port",
+				Value:   "unix:///run/vault.sock",
+			},
+			&cli.StringSliceFlag{
+				Name:     CliServeAllowPrefix,
+				EnvVars:  []string{getFlagEnvByFlagName("serve_" + strings.ReplaceAll(CliServeAllowPrefix, "-", "_"))},
+				Usage:    "Value prefix which may be served like VALUES.app, can be set multiple times",
+				Required: true,
+			},
+			&cli.DurationFlag{
+				Name:    CliServeCacheTTL,
+				EnvVars: []string{getFlagEnvByFlagName("serve_" + strings.ReplaceAll(CliServeCacheTTL, "-", "_"))},
+				Usage:   "How long a fetched value is kept in memory, 0 disables the cache",
+				Value:   time.Minute,
+			},
+			&cli.StringFlag{
+				Name:  CliServeSocketMode,
+				Usage: "File mode of the unix socket",
+				Value: "0600",
+			},
+			&cli.BoolFlag{
+				Name:  CliServeInsecureListen,
+				Usage: "Allow a tcp address which is not loopback, everyone who can reach it can read the served values",
+			},
+		),
+	}
+}
+
+// listen opens the listener for unix://path or tcp://host:port.
+// Tcp hosts must be loopback unless insecure is set, the api has no authentication.
+func listen(address, socketMode string, insecure bool) (net.Listener, error) {
+	if path, isUnix := strings.CutPrefix(address, "unix://"); isUnix {
+		mode, err := strconv.ParseUint(socketMode, 8, 32)
+		if err != nil {
+			return nil, withExitCode(ExitUsage, fmt.Errorf("invalid socket mode %s: %w", socketMode, err))
+		}
+		// a socket left over by a killed server would block the listen, a running server must not lose its socket
+		if info, err := os.Stat(path); err == nil && info.Mode()&os.ModeSocket != 0 {
+			if conn, err := net.DialTimeout("unix", path, time.Second); err == nil {
+				conn.Close()
+				return nil, fmt.Errorf("socket %s is in use by an other server", path)
+			}
+			if err := os.Remove(path); err != nil {
+				return nil, err
+			}
+		}
+		l, err := net.Listen("unix", path)
+		if err != nil {
+			return nil, err
+		}
+		if err := os.Chmod(path, os.FileMode(mode)); err != nil {
+			l.Close()
+			return nil, err
+		}
+		return l, nil
+	}
+	if hostPort, isTcp := strings.CutPrefix(address, "tcp://"); isTcp {
+		host, _, err := net.SplitHostPort(hostPort)
+		if err != nil {
+			return nil, withExitCode(ExitUsage, fmt.Errorf("invalid listen address %s: %w", address, err))
+		}
+		if !insecure && !isLoopbackHost(host) {
+			return nil, withExitCode(ExitUsage, fmt.Errorf("listen address %s is not loopback, use --%s to serve values to the network without authentication", address, CliServeInsecureListen))
+		}
+		return net.Listen("tcp", hostPort)
+	}
+	return nil, withExitCode(ExitUsage, fmt.Errorf("invalid listen address %s, expected unix:// or tcp://", address))
+}
+
+func isLoopbackHost(host string) bool {
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+func (r *ProtectedRunner) Serve(c *cli.Context) error {
+	log := logger.GetWithStructAndFunc("ProtectedRunner", "Serve")
+	prefixes := make([]string, 0)
+	for _, prefix := range c.StringSlice(CliServeAllowPrefix) {
+		prefix = strings.TrimSuffix(prefix, ".")
+		if prefix != "VALUES" && !ValueNameRegex.MatchString(prefix) {
+			return withExitCode(ExitUsage, fmt.Errorf("invalid prefix %s, expected something like VALUES.app", prefix))
+		}
+		prefixes = append(prefixes, prefix)
+	}
+	server := &valueServer{
+		pRunner:  r,
+		prefixes: prefixes,
+		ttl:      c.Duration(CliServeCacheTTL),
+		cache:    map[string]servedValue{},
+	}
+	l, err := listen(c.String(CliServeListen), c.String(CliServeSocketMode), c.Bool(CliServeInsecureListen))
+	if err != nil {
+		return err
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc(serveValuesPath, server.handleValue)
+	mux.HandleFunc("/v1/health", func(w http.ResponseWriter, req *http.Request) {
+		fmt.Fprintln(w, "ok")
+	})
+	srv := &http.Server{Handler: server.logRequests(mux), ReadHeaderTimeout: 10 * time.Second}
+
+	ctx, stop := signal.NotifyContext(c.Context, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		srv.Shutdown(shutdownCtx)
+	}()
+	log.Infow("serve values", "listen", c.String(CliServeListen), "prefixes", prefixes, "cacheTTL", server.ttl)
+	if err := srv.Serve(l); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+func (s *valueServer) allowed(name string) bool {
+	for _, prefix := range s.prefixes {
+		if name == prefix || strings.HasPrefix(name, prefix+".") {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *valueServer) handleValue(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet {
+		writeServeError(w, http.StatusMethodNotAllowed, "only GET is allowed")
+		return
+	}
+	name := strings.TrimPrefix(req.URL.Path, serveValuesPath)
+	if !ValueNameRegex.MatchString(name) {
+		writeServeError(w, http.StatusBadRequest, fmt.Sprintf("invalid value name %s", name))
+		return
+	}
+	if !s.allowed(name) {
+		writeServeError(w, http.StatusForbidden, fmt.Sprintf("value %s is not below an allowed prefix", name))
+		return
+	}
+	value, err := s.value(name)
+	if err != nil {
+		status := http.StatusInternalServerError
+		switch exitCode(err) {
+		case ExitNotFound:
+			status = http.StatusNotFound
+		case ExitAuth:
+			status = http.StatusForbidden
+		case ExitNetwork:
+			status = http.StatusBadGateway
+		}
+		writeServeError(w, status, logger.RedactString(err.Error()))
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	fmt.Fprint(w, value)
+}
+
+// value returns name from the in memory cache or fetches it from the server.
+func (s *valueServer) value(name string) (string, error) {
+	now := time.Now()
+	s.mu.Lock()
+	cached, ok := s.cache[name]
+	s.mu.Unlock()
+	if ok && now.Before(cached.expires) {
+		return cached.value, nil
+	}
+	value, err := s.pRunner.fetchValue(name)
+	if err != nil {
+		return "", err
+	}
+	if s.ttl > 0 {
+		s.mu.Lock()
+		s.cache[name] = servedValue{value: value, expires: now.Add(s.ttl)}
+		s.mu.Unlock()
+	}
+	return value, nil
+}
+
+func (s *valueServer) logRequests(next http.Handler) http.Handler {
+	log := logger.GetWithStructAndFunc("valueServer", "request")
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rec, req)
+		log.Infow("request", "method", req.Method, "path", req.URL.Path, "status", rec.status, "duration", time.Since(start).String())
+	})
+}
+
+func writeServeError(w http.ResponseWriter, status int, msg string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"error": msg})
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"crypto/rand"
+	"fmt"
+)
+
+// Shamir secret sharing over GF(256) with the AES polynomial x^8 + x^4 + x^3 + x + 1.
+// Every share is the byte wise evaluation of a random polynomial at x, where the secret is the value at 0.
+
+var gfExp [510]byte
+var gfLog [256]byte
+
+func init() {
+	x := byte(1)
+	for i := 0; i < 255; i++ {
+		gfExp[i] = x
+		gfExp[i+255] = x
+		gfLog[x] = byte(i)
+		// multiply by the generator 3
+		x ^= gfMulSlow(x, 2)
+	}
+}
+
+func gfMulSlow(a, b byte) byte {
+	var p byte
+	for b > 0 {
+		if b&1 == 1 {
+			p ^= a
+		}
+		carry := a & 0x80
+		a <<= 1
+		if carry != 0 {
+			a ^= 0x1b
+		}
+		b >>= 1
+	}
+	return p
+}
+
+func gfMul(a, b byte) byte {
+	if a == 0 || b == 0 {
+		return 0
+	}
+	return gfExp[int(gfLog[a])+int(gfLog[b])]
+}
+
+func gfDiv(a, b byte) byte {
+	if a == 0 {
+		return 0
+	}
+	return gfExp[int(gfLog[a])+255-int(gfLog[b])]
+}
+
+// shamirSplit splits secret into n shares where threshold of them are required to combine it.
+// The returned map is keyed by the x coordinate of the share.
+func shamirSplit(secret []byte, n, threshold int) (map[byte][]byte, error) {
+	if threshold < 2 || threshold > n || n > 255 {
+		return nil, fmt.Errorf("invalid shares %d and threshold %d, need 2 <= threshold <= shares <= 255", n, threshold)
+	}
+	coefficients := make([]byte, threshold-1)
+	shares := make(map[byte][]byte, n)
+	for x := 1; x <= n; x++ {
+		shares[byte(x)] = make([]byte, len(secret))
+	}
+	for i, s := range secret {
+		if _, err := rand.Read(coefficients); err != nil {
+			return nil, err
+		}
+		for x, share := range shares {
+			// horner scheme, highest coefficient first
+			y := byte(0)
+			for j := len(coefficients) - 1; j >= 0; j-- {
+				y = gfMul(y, x) ^ coefficients[j]
+			}
+			share[i] = gfMul(y, x) ^ s
+		}
+	}
+	return shares, nil
+}
+
+// shamirCombine reconstructs the secret by lagrange interpolation at 0.
+func shamirCombine(shares map[byte][]byte) ([]byte, error) {
+	length := -1
+	for x, share := range shares {
+		if x == 0 {
+			return nil, fmt.Errorf("invalid share index 0")
+		}
+		if length != -1 && len(share) != length {
+			return nil, fmt.Errorf("shares have different length")
+		}
+		length = len(share)
+	}
+	if length < 0 {
+		return nil, fmt.Errorf("no shares given")
+	}
+	secret := make([]byte, length)
+	for xi, share := range shares {
+		basis := byte(1)
+		for xj := range shares {
+			if xi == xj {
+				continue
+			}
+			basis = gfMul(basis, gfDiv(xj, xj^xi))
+		}
+		for i := range secret {
+			secret[i] ^= gfMul(share[i], basis)
+		}
+	}
+	return secret, nil
+}
@@ -0,0 +1,307 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/urfave/cli/v2"
+)
+
+// SnapshotVersion 2 added id and fingerprint of identities, version 1 snapshots have to be saved again.
+const SnapshotVersion = 2
+
+// VaultSnapshot describes identities, rights and value names of a vault without any secret.
+type VaultSnapshot struct {
+	Version    int                `json:"version"`
+	VaultId    string             `json:"vaultId"`
+	Identities []SnapshotIdentity `json:"identities"`
+	Values     []string           `json:"values"`
+}
+
+// SnapshotIdentity is identified by its id, the id is derived from the public key so a replaced key is a new identity.
+type SnapshotIdentity struct {
+	Id          string   `json:"id"`
+	Name        string   `json:"name"`
+	Fingerprint string   `json:"fingerprint"`
+	Rights      []string `json:"rights"`
+}
+
+type DriftIdentity struct {
+	Id          string `json:"id"`
+	Name        string `json:"name"`
+	Fingerprint string `json:"fingerprint"`
+}
+
+type RightsDrift struct {
+	Id            string   `json:"id"`
+	Name          string   `json:"name"`
+	RightsAdded   []string `json:"rightsAdded"`
+	RightsRemoved []string `json:"rightsRemoved"`
+}
+
+// DriftReport lists all differences between a snapshot and the live vault.
+// Added means present at the live vault but not at the snapshot.
+type DriftReport struct {
+	VaultId           string          `json:"vaultId"`
+	Drift             bool            `json:"drift"`
+	IdentitiesAdded   []DriftIdentity `json:"identitiesAdded"`
+	IdentitiesRemoved []DriftIdentity `json:"identitiesRemoved"`
+	RightsChanged     []RightsDrift   `json:"rightsChanged"`
+	ValuesAdded       []string        `json:"valuesAdded"`
+	ValuesRemoved     []string        `json:"valuesRemoved"`
+}
+
+func GetSnapshotCommand(pRunner *ProtectedRunner) *cli.Command {
+	return &cli.Command{
+		Name:  "snapshot",
+		Usage: "Save or check identities, rights and value names of a vault",
+		Subcommands: []*cli.Command{
+			{
+				Name:   "save",
+				Usage:  "Write the current state of the vault to a file, no secrets are included",
+				Action: pRunner.SaveSnapshot,
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:     CliSnapshotOut,
+						Usage:    "File to write the snapshot to",
+						Required: true,
+					},
+				},
+			},
+			{
+				Name:   "check",
+				Usage:  "Compare the vault against a snapshot, exit with code 1 if they differ",
+				Action: pRunner.CheckSnapshot,
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:     CliSnapshotAgainst,
+						Usage:    "Snapshot file to compare with",
+						Required: true,
+					},
+					&cli.StringFlag{
+						Name:  CliSnapshotFormat,
+						Usage: "Format of the drift report text or json",
+						Value: "text",
+					},
+				},
+			},
+		},
+	}
+}
+
+func (r *ProtectedRunner) currentSnapshot(c *cli.Context) (*VaultSnapshot, error) {
+	queried, err := r.queryIdentities(c)
+	if err != nil {
+		return nil, err
+	}
+	identities := make([]SnapshotIdentity, 0, len(queried))
+	for _, identity := range queried {
+		rights := make([]string, 0, len(identity.Rights))
+		for _, right := range identity.Rights {
+			rights = append(rights, rightString(right.Right, right.RightValuePattern))
+		}
+		sort.Strings(rights)
+		identities = append(identities, SnapshotIdentity{Id: identity.Id, Name: identityName(identity), Fingerprint: identity.fingerprint(), Rights: rights})
+	}
+	sort.Slice(identities, func(i, j int) bool {
+		if identities[i].Name == identities[j].Name {
+			return identities[i].Id < identities[j].Id
+		}
+		return identities[i].Name < identities[j].Name
+	})
+
+	identityId, err := identityIdOfKey(&r.privateKey.PublicKey, *r.vaultId)
+	if err != nil {
+		return nil, err
+	}
+	related, err := r.api.GetAllRelatedValues(identityId)
+	if err != nil {
+		return nil, err
+	}
+	values := make([]string, 0, len(related))
+	for _, v := range related {
+		values = append(values, v.Name)
+	}
+	sort.Strings(values)
+
+	return &VaultSnapshot{
+		Version:    SnapshotVersion,
+		VaultId:    *r.vaultId,
+		Identities: identities,
+		Values:     values,
+	}, nil
+}
+
+func (r *ProtectedRunner) SaveSnapshot(c *cli.Context) error {
+	snapshot, err := r.currentSnapshot(c)
+	if err != nil {
+		return err
+	}
+	content, err := json.MarshalIndent(snapshot, "", "  ")
+	if err != nil {
+		return err
+	}
+	err = os.WriteFile(c.String(CliSnapshotOut), append(content, '\n'), 0644)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Snapshot with %d identities and %d values saved at %s\n", len(snapshot.Identities), len(snapshot.Values), c.String(CliSnapshotOut))
+	return nil
+}
+
+func (r *ProtectedRunner) CheckSnapshot(c *cli.Context) error {
+	format := c.String(CliSnapshotFormat)
+	if format != "text" && format != "json" {
+		return fmt.Errorf("unknown format %s", format)
+	}
+	content, err := os.ReadFile(c.String(CliSnapshotAgainst))
+	if err != nil {
+		return err
+	}
+	expected := VaultSnapshot{}
+	if err := json.Unmarshal(content, &expected); err != nil {
+		return err
+	}
+	if expected.Version != SnapshotVersion {
+		return fmt.Errorf("unsupported snapshot version %d, save the snapshot again", expected.Version)
+	}
+	current, err := r.currentSnapshot(c)
+	if err != nil {
+		return err
+	}
+	if expected.VaultId != current.VaultId {
+		return fmt.Errorf("snapshot belongs to vault %s not to %s", expected.VaultId, current.VaultId)
+	}
+
+	report := compareSnapshots(&expected, current)
+	if format == "json" {
+		out, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(out))
+	} else {
+		printDriftReport(report)
+	}
+	if report.Drift {
+		return silentExit(ExitError)
+	}
+	return nil
+}
+
+// compareSnapshots compares identities by id, so a replaced key or a second identity with a known name is a drift.
+func compareSnapshots(expected, current *VaultSnapshot) *DriftReport {
+	expectedById := snapshotIdentitiesById(expected)
+	currentById := snapshotIdentitiesById(current)
+	report := &DriftReport{
+		VaultId:           current.VaultId,
+		IdentitiesAdded:   make([]DriftIdentity, 0),
+		IdentitiesRemoved: make([]DriftIdentity, 0),
+		RightsChanged:     make([]RightsDrift, 0),
+	}
+	for id, identity := range currentById {
+		old, ok := expectedById[id]
+		if !ok {
+			report.IdentitiesAdded = append(report.IdentitiesAdded, identity.drift())
+			continue
+		}
+		added, removed := diffStrings(old.Rights, identity.Rights)
+		if len(added) > 0 || len(removed) > 0 {
+			report.RightsChanged = append(report.RightsChanged, RightsDrift{Id: id, Name: identity.Name, RightsAdded: added, RightsRemoved: removed})
+		}
+	}
+	for id, identity := range expectedById {
+		if _, ok := currentById[id]; !ok {
+			report.IdentitiesRemoved = append(report.IdentitiesRemoved, identity.drift())
+		}
+	}
+	sortDriftIdentities(report.IdentitiesAdded)
+	sortDriftIdentities(report.IdentitiesRemoved)
+	sort.Slice(report.RightsChanged, func(i, j int) bool {
+		if report.RightsChanged[i].Name == report.RightsChanged[j].Name {
+			return report.RightsChanged[i].Id < report.RightsChanged[j].Id
+		}
+		return report.RightsChanged[i].Name < report.RightsChanged[j].Name
+	})
+	report.ValuesAdded, report.ValuesRemoved = diffStrings(expected.Values, current.Values)
+	report.Drift = len(report.IdentitiesAdded)+len(report.IdentitiesRemoved)+len(report.RightsChanged)+len(report.ValuesAdded)+len(report.ValuesRemoved) > 0
+	return report
+}
+
+func snapshotIdentitiesById(snapshot *VaultSnapshot) map[string]SnapshotIdentity {
+	result := make(map[string]SnapshotIdentity, len(snapshot.Identities))
+	for _, identity := range snapshot.Identities {
+		result[identity.Id] = identity
+	}
+	return result
+}
+
+func (i SnapshotIdentity) drift() DriftIdentity {
+	return DriftIdentity{Id: i.Id, Name: i.Name, Fingerprint: i.Fingerprint}
+}
+
+func sortDriftIdentities(identities []DriftIdentity) {
+	sort.Slice(identities, func(i, j int) bool {
+		if identities[i].Name == identities[j].Name {
+			return identities[i].Id < identities[j].Id
+		}
+		return identities[i].Name < identities[j].Name
+	})
+}
+
+// diffStrings returns the sorted entries only in b (added) and only in a (removed).
+func diffStrings(a, b []string) (added []string, removed []string) {
+	inA := make(map[string]bool, len(a))
+	for _, v := range a {
+		inA[v] = true
+	}
+	inB := make(map[string]bool, len(b))
+	for _, v := range b {
+		inB[v] = true
+	}
+	added, removed = make([]string, 0), make([]string, 0)
+	for v := range inB {
+		if !inA[v] {
+			added = append(added, v)
+		}
+	}
+	for v := range inA {
+		if !inB[v] {
+			removed = append(removed, v)
+		}
+	}
+	sort.Strings(added)
+	sort.Strings(removed)
+	return added, removed
+}
+
+func printDriftReport(report *DriftReport) {
+	if !report.Drift {
+		fmt.Printf("No drift found for vault %s\n", report.VaultId)
+		return
+	}
+	fmt.Printf("Drift found for vault %s\n", report.VaultId)
+	for _, identity := range report.IdentitiesAdded {
+		fmt.Printf("+ identity %s\t%s\t%s\n", identity.Name, identity.Id, identity.Fingerprint)
+	}
+	for _, identity := range report.IdentitiesRemoved {
+		fmt.Printf("- identity %s\t%s\t%s\n", identity.Name, identity.Id, identity.Fingerprint)
+	}
+	for _, changed := range report.RightsChanged {
+		fmt.Printf("~ identity %s\t%s\n", changed.Name, changed.Id)
+		for _, right := range changed.RightsAdded {
+			fmt.Printf("\t+ %s\n", right)
+		}
+		for _, right := range changed.RightsRemoved {
+			fmt.Printf("\t- %s\n", right)
+		}
+	}
+	for _, name := range report.ValuesAdded {
+		fmt.Printf("+ value %s\n", name)
+	}
+	for _, name := range report.ValuesRemoved {
+		fmt.Printf("- value %s\n", name)
+	}
+}
@@ -0,0 +1,224 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cryptvault-cloud/vault-cli/logger"
+	"github.com/urfave/cli/v2"
+)
+
+// redactJson replaces all sensitive fields of a json document, invalid json is dropped completely.
+func redactJson(content []byte) any {
+	if len(content) == 0 {
+		return nil
+	}
+	var doc any
+	if err := json.Unmarshal(content, &doc); err != nil {
+		return logger.Redacted
+	}
+	return logger.RedactAny(doc)
+}
+
+func redactHeaders(header http.Header) []harNameValue {
+	result := make([]harNameValue, 0, len(header))
+	for name, values := range header {
+		value := strings.Join(values, ", ")
+		if logger.IsSensitiveKey(name) {
+			value = logger.Redacted
+		}
+		result = append(result, harNameValue{Name: name, Value: value})
+	}
+	return result
+}
+
+// The har types only contain the fields needed to open a trace with HAR viewers.
+type harNameValue struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type harEntry struct {
+	StartedDateTime time.Time   `json:"startedDateTime"`
+	Time            float64     `json:"time"`
+	Request         harRequest  `json:"request"`
+	Response        harResponse `json:"response"`
+	Comment         string      `json:"comment,omitempty"`
+}
+
+type harRequest struct {
+	Method   string         `json:"method"`
+	Url      string         `json:"url"`
+	Headers  []harNameValue `json:"headers"`
+	PostData *harContent    `json:"postData,omitempty"`
+}
+
+type harResponse struct {
+	Status     int            `json:"status"`
+	StatusText string         `json:"statusText"`
+	Headers    []harNameValue `json:"headers"`
+	Content    harContent     `json:"content"`
+	Error      string         `json:"_error,omitempty"`
+}
+
+type harContent struct {
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text"`
+}
+
+type harLog struct {
+	Log struct {
+		Version string `json:"version"`
+		Creator struct {
+			Name    string `json:"name"`
+			Version string `json:"version"`
+		} `json:"creator"`
+		Entries []harEntry `json:"entries"`
+	} `json:"log"`
+}
+
+// tracer collects all requests to the server for --trace and --trace-file.
+type tracer struct {
+	mu      sync.Mutex
+	log     bool
+	file    string
+	entries []harEntry
+}
+
+func traceFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.BoolFlag{
+			Name:    CliTrace,
+			EnvVars: []string{getFlagEnvByFlagName(CliTrace)},
+			Usage:   "Log each GraphQL operation with redacted variables, status, latency and errors",
+		},
+		&cli.StringFlag{
+			Name:    CliTraceFile,
+			EnvVars: []string{getFlagEnvByFlagName(strings.ReplaceAll(CliTraceFile, "-", "_"))},
+			Usage:   "Write all requests with redacted secrets as HAR-like json to this file",
+		},
+	}
+}
+
+func newTracer(c *cli.Context) *tracer {
+	if !c.Bool(CliTrace) && c.String(CliTraceFile) == "" {
+		return nil
+	}
+	return &tracer{log: c.Bool(CliTrace), file: c.String(CliTraceFile), entries: make([]harEntry, 0)}
+}
+
+func (t *tracer) add(entry harEntry, operation string, variables any, errs []string) {
+	if t.log {
+		logger.GetWithStructAndFunc("tracer", "add").Infow("graphql request",
+			"operation", operation,
+			"variables", variables,
+			"status", entry.Response.Status,
+			"latency", time.Duration(entry.Time*float64(time.Millisecond)).String(),
+			"errors", errs,
+			"error", entry.Response.Error,
+		)
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.entries = append(t.entries, entry)
+}
+
+// write saves all traced requests as HAR-like json.
+func (t *tracer) write() error {
+	if t == nil || t.file == "" {
+		return nil
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	har := harLog{}
+	har.Log.Version = "1.2"
+	har.Log.Creator.Name = "vault-cli"
+	har.Log.Creator.Version = version
+	har.Log.Entries = t.entries
+	content, err := json.MarshalIndent(har, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(t.file, content, 0600)
+}
+
+type traceTransport struct {
+	wrapped http.RoundTripper
+	tracer  *tracer
+}
+
+func (t *traceTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	request := struct {
+		OperationName string `json:"operationName"`
+		Variables     any    `json:"variables"`
+	}{}
+	var requestBody []byte
+	if req.Body != nil {
+		var err error
+		requestBody, err = io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		req.Body = io.NopCloser(bytes.NewReader(requestBody))
+		_ = json.Unmarshal(requestBody, &request)
+	}
+
+	entry := harEntry{
+		StartedDateTime: time.Now(),
+		Comment:         request.OperationName,
+		Request: harRequest{
+			Method:  req.Method,
+			Url:     req.URL.String(),
+			Headers: redactHeaders(req.Header),
+		},
+	}
+	if requestBody != nil {
+		postData, _ := json.Marshal(redactJson(requestBody))
+		entry.Request.PostData = &harContent{MimeType: req.Header.Get("Content-Type"), Text: string(postData)}
+	}
+
+	res, err := t.wrapped.RoundTrip(req)
+	entry.Time = float64(time.Since(entry.StartedDateTime).Microseconds()) / 1000
+	var errs []string
+	if err != nil {
+		entry.Response.Error = err.Error()
+	} else {
+		responseBody, readErr := io.ReadAll(res.Body)
+		res.Body.Close()
+		res.Body = io.NopCloser(bytes.NewReader(responseBody))
+		if readErr != nil {
+			entry.Response.Error = readErr.Error()
+		}
+		entry.Response.Status = res.StatusCode
+		entry.Response.StatusText = http.StatusText(res.StatusCode)
+		entry.Response.Headers = redactHeaders(res.Header)
+		content, _ := json.Marshal(redactJson(responseBody))
+		entry.Response.Content = harContent{MimeType: res.Header.Get("Content-Type"), Text: string(content)}
+		errs = graphqlErrors(responseBody)
+	}
+	t.tracer.add(entry, request.OperationName, logger.RedactAny(request.Variables), errs)
+	return res, err
+}
+
+func graphqlErrors(body []byte) []string {
+	response := struct {
+		Errors []struct {
+			Message string `json:"message"`
+		} `json:"errors"`
+	}{}
+	if err := json.Unmarshal(body, &response); err != nil {
+		return nil
+	}
+	result := make([]string, 0, len(response.Errors))
+	for _, e := range response.Errors {
+		result = append(result, e.Message)
+	}
+	return result
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/cryptvault-cloud/vault-cli/logger"
+	"github.com/urfave/cli/v2"
+)
+
+func transportFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.DurationFlag{
+			Name:    CliHttpTimeout,
+			EnvVars: []string{getFlagEnvByFlagName(CliHttpTimeout)},
+			Value:   30 * time.Second,
+			Usage:   "Timeout to connect and to wait for the response of the server",
+		},
+		&cli.IntFlag{
+			Name:    CliHttpRetries,
+			EnvVars: []string{getFlagEnvByFlagName(CliHttpRetries)},
+			Value:   3,
+			Usage:   "Retries with exponential backoff of queries on connection errors and 5xx responses, mutations are never retried",
+		},
+		&cli.StringFlag{
+			Name:    CliHttpProxy,
+			EnvVars: []string{getFlagEnvByFlagName(CliHttpProxy)},
+			Usage:   "Proxy url, HTTPS_PROXY and NO_PROXY are used if not set",
+		},
+		&cli.StringFlag{
+			Name:    CliHttpCaFile,
+			EnvVars: []string{getFlagEnvByFlagName(strings.ReplaceAll(CliHttpCaFile, "-", "_"))},
+			Usage:   "Pem file with additional CA certificates to trust, f.e. of a TLS inspecting proxy",
+		},
+		&cli.StringFlag{
+			Name:    CliHttpClientCert,
+			EnvVars: []string{getFlagEnvByFlagName(strings.ReplaceAll(CliHttpClientCert, "-", "_"))},
+			Usage:   "Pem file with a client certificate for mTLS",
+		},
+		&cli.StringFlag{
+			Name:    CliHttpClientKey,
+			EnvVars: []string{getFlagEnvByFlagName(strings.ReplaceAll(CliHttpClientKey, "-", "_"))},
+			Usage:   "Pem file with the private key of the client certificate",
+		},
+	}
+}
+
+// newTransport builds the transport for all requests to the server by the global flags.
+// Requests are traced if t is set.
+func newTransport(c *cli.Context, t *tracer) (http.RoundTripper, error) {
+	timeout := c.Duration(CliHttpTimeout)
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+
+	if caFile := c.String(CliHttpCaFile); caFile != "" {
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		content, err := os.ReadFile(caFile)
+		if err != nil {
+			return nil, err
+		}
+		if !pool.AppendCertsFromPEM(content) {
+			return nil, fmt.Errorf("no certificate found at %s", caFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	certFile, keyFile := c.String(CliHttpClientCert), c.String(CliHttpClientKey)
+	if (certFile == "") != (keyFile == "") {
+		return nil, fmt.Errorf("--%s and --%s have to be set together", CliHttpClientCert, CliHttpClientKey)
+	}
+	if certFile != "" {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	proxy := http.ProxyFromEnvironment
+	if proxyUrl := c.String(CliHttpProxy); proxyUrl != "" {
+		parsed, err := url.Parse(proxyUrl)
+		if err != nil {
+			return nil, fmt.Errorf("invalid proxy url: %w", err)
+		}
+		proxy = http.ProxyURL(parsed)
+	}
+
+	base := &http.Transport{
+		Proxy:                 proxy,
+		DialContext:           (&net.Dialer{Timeout: timeout, KeepAlive: 30 * time.Second}).DialContext,
+		TLSClientConfig:       tlsConfig,
+		TLSHandshakeTimeout:   timeout,
+		ResponseHeaderTimeout: timeout,
+		IdleConnTimeout:       90 * time.Second,
+		ForceAttemptHTTP2:     true,
+	}
+	var wrapped http.RoundTripper = base
+	if t != nil {
+		wrapped = &traceTransport{wrapped: base, tracer: t}
+	}
+	return &retryTransport{wrapped: wrapped, retries: c.Int(CliHttpRetries), backoff: 500 * time.Millisecond}, nil
+}
+
+// retryTransport retries GraphQL queries, mutations are never sent twice.
+type retryTransport struct {
+	wrapped http.RoundTripper
+	retries int
+	backoff time.Duration
+}
+
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Body == nil || t.retries <= 0 {
+		return t.wrapped.RoundTrip(req)
+	}
+	body, err := io.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	if !isGraphqlQuery(body) {
+		return t.wrapped.RoundTrip(req)
+	}
+
+	log := logger.GetWithStructAndFunc("retryTransport", "RoundTrip")
+	backoff := t.backoff
+	for attempt := 0; ; attempt++ {
+		retryReq := req.Clone(req.Context())
+		retryReq.Body = io.NopCloser(bytes.NewReader(body))
+		res, err := t.wrapped.RoundTrip(retryReq)
+		if attempt >= t.retries || !shouldRetry(res, err) {
+			return res, err
+		}
+		if err != nil {
+			log.Warnw("request failed, retry", "attempt", attempt+1, "backoff", backoff, "error", err)
+		} else {
+			log.Warnw("request failed, retry", "attempt", attempt+1, "backoff", backoff, "status", res.StatusCode)
+			res.Body.Close()
+		}
+		select {
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+func shouldRetry(res *http.Response, err error) bool {
+	if err != nil {
+		var netErr net.Error
+		return errors.As(err, &netErr) || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) || strings.Contains(err.Error(), "connection reset")
+	}
+	return res.StatusCode == http.StatusBadGateway || res.StatusCode == http.StatusServiceUnavailable || res.StatusCode == http.StatusGatewayTimeout
+}
+
+// isGraphqlQuery reports whether the request body is a GraphQL query which is safe to repeat.
+func isGraphqlQuery(body []byte) bool {
+	request := struct {
+		Query string `json:"query"`
+	}{}
+	if err := json.Unmarshal(body, &request); err != nil {
+		return false
+	}
+	operation := strings.TrimSpace(request.Query)
+	return strings.HasPrefix(operation, "query") || strings.HasPrefix(operation, "{")
+}
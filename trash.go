@@ -0,0 +1,244 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/urfave/cli/v2"
+)
+
+const (
+	TrashFolder     = ".trash"
+	trashTimeLayout = "20060102T150405Z"
+)
+
+// trashEntry is one deleted folder, stored at .trash/<id>/files with its origin path next to it.
+type trashEntry struct {
+	Id        string
+	Origin    string
+	DeletedAt time.Time
+	Files     []string
+}
+
+func GetLocalTrashCommand(runner *Runner) *cli.Command {
+	return &cli.Command{
+		Name:        "trash",
+		Usage:       "Deleted local identities",
+		Description: "Deleted identity folders are moved to the trash, so a mistakenly deleted identity can be re-added with its original key.",
+		Subcommands: []*cli.Command{
+			{
+				Name:   "ls",
+				Usage:  "List the trash",
+				Action: runner.LocalTrashList,
+			},
+			{
+				Name:   "restore",
+				Usage:  "Move a folder from the trash back to its origin",
+				Action: runner.audited(runner.LocalTrashRestore),
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:     CliTrashId,
+						Usage:    "Id of the trash entry, see trash ls",
+						Required: true,
+					},
+				},
+			},
+			{
+				Name:   "purge",
+				Usage:  "Delete entries of the trash forever",
+				Action: runner.audited(runner.LocalTrashPurge),
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:  CliTrashOlderThan,
+						Usage: "Only entries older than this f.e. 30d or 12h, all entries if not set",
+					},
+					&cli.BoolFlag{
+						Name:  CliDeleteYes,
+						Usage: "Purge without asking",
+					},
+				},
+			},
+		},
+	}
+}
+
+// parseAge parses durations like 30d in addition to everything time.ParseDuration understands.
+func parseAge(value string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(value, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("invalid age %s", value)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	age, err := time.ParseDuration(value)
+	if err != nil || age < 0 {
+		return 0, fmt.Errorf("invalid age %s, use something like 30d or 12h", value)
+	}
+	return age, nil
+}
+
+// moveToTrash moves all files of folder to a new trash entry and returns its id.
+// An empty id is returned if there is nothing to move.
+func (r *Runner) moveToTrash(folder string) (string, error) {
+	files, err := r.readFolder(folder)
+	if err != nil {
+		return "", err
+	}
+	if files == nil {
+		return "", nil
+	}
+	entryFolder, err := r.uniqueFolder(fmt.Sprintf("%s/%s", TrashFolder, time.Now().UTC().Format(trashTimeLayout)), nil)
+	if err != nil {
+		return "", err
+	}
+	err = r.fileHandler.SaveTextToFile(fmt.Sprintf("%s/origin", entryFolder), folder)
+	for f, content := range files {
+		if err != nil {
+			break
+		}
+		err = r.fileHandler.SaveTextToFile(fmt.Sprintf("%s/files/%s", entryFolder, f), content)
+	}
+	if err != nil {
+		return "", err
+	}
+	if err := r.fileHandler.DeleteFolder(folder); err != nil {
+		return "", err
+	}
+	return strings.TrimPrefix(entryFolder, TrashFolder+"/"), nil
+}
+
+func (r *Runner) trashEntries() ([]*trashEntry, error) {
+	files, err := r.fileHandler.ListFiles(TrashFolder)
+	if err != nil {
+		// no trash folder yet
+		return []*trashEntry{}, nil
+	}
+	entries := make(map[string]*trashEntry)
+	for _, f := range files {
+		id, rest, ok := strings.Cut(f, "/")
+		if !ok {
+			continue
+		}
+		entry, ok := entries[id]
+		if !ok {
+			entry = &trashEntry{Id: id}
+			entries[id] = entry
+		}
+		if name, isFile := strings.CutPrefix(rest, "files/"); isFile {
+			entry.Files = append(entry.Files, name)
+		}
+	}
+	result := make([]*trashEntry, 0, len(entries))
+	for _, entry := range entries {
+		origin, err := r.fileHandler.ReadTextFile(fmt.Sprintf("%s/%s/origin", TrashFolder, entry.Id))
+		if err != nil {
+			return nil, fmt.Errorf("trash entry %s is broken: %w", entry.Id, err)
+		}
+		entry.Origin = strings.TrimSpace(origin)
+		timestamp, _, _ := strings.Cut(entry.Id, "-")
+		entry.DeletedAt, err = time.Parse(trashTimeLayout, timestamp)
+		if err != nil {
+			return nil, fmt.Errorf("trash entry %s has no valid timestamp", entry.Id)
+		}
+		sort.Strings(entry.Files)
+		result = append(result, entry)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Id < result[j].Id })
+	return result, nil
+}
+
+func (r *Runner) trashEntry(id string) (*trashEntry, error) {
+	entries, err := r.trashEntries()
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range entries {
+		if entry.Id == id {
+			return entry, nil
+		}
+	}
+	return nil, withExitCode(ExitNotFound, fmt.Errorf("trash entry %s not found", id))
+}
+
+func (r *Runner) LocalTrashList(c *cli.Context) error {
+	entries, err := r.trashEntries()
+	if err != nil {
+		return err
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tDELETED\tORIGIN\tFILES")
+	for _, entry := range entries {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%d\n", entry.Id, entry.DeletedAt.Local().Format(time.DateTime), entry.Origin, len(entry.Files))
+	}
+	return w.Flush()
+}
+
+func (r *Runner) LocalTrashRestore(c *cli.Context) error {
+	entry, err := r.trashEntry(c.String(CliTrashId))
+	if err != nil {
+		return err
+	}
+	if existing, err := r.readFolder(entry.Origin); err != nil {
+		return err
+	} else if existing != nil {
+		return withExitCode(ExitConflict, fmt.Errorf("%s already exists, remove or rename it first", entry.Origin))
+	}
+	entryFolder := fmt.Sprintf("%s/%s", TrashFolder, entry.Id)
+	for _, f := range entry.Files {
+		content, err := r.fileHandler.ReadTextFile(fmt.Sprintf("%s/files/%s", entryFolder, f))
+		if err != nil {
+			return err
+		}
+		if err := r.fileHandler.SaveTextToFile(fmt.Sprintf("%s/%s", entry.Origin, f), content); err != nil {
+			return err
+		}
+	}
+	if err := r.fileHandler.DeleteFolder(entryFolder); err != nil {
+		return err
+	}
+	fmt.Printf("Restored %s\n", r.fileHandler.FullPath(entry.Origin))
+	return nil
+}
+
+func (r *Runner) LocalTrashPurge(c *cli.Context) error {
+	var age time.Duration
+	if olderThan := c.String(CliTrashOlderThan); olderThan != "" {
+		var err error
+		age, err = parseAge(olderThan)
+		if err != nil {
+			return err
+		}
+	}
+	entries, err := r.trashEntries()
+	if err != nil {
+		return err
+	}
+	deadline := time.Now().Add(-age)
+	purge := make([]*trashEntry, 0)
+	for _, entry := range entries {
+		if entry.DeletedAt.Before(deadline) {
+			purge = append(purge, entry)
+			fmt.Printf("%s\t%s\n", entry.Id, entry.Origin)
+		}
+	}
+	if len(purge) == 0 {
+		fmt.Println("Nothing to purge")
+		return nil
+	}
+	if err := confirmDestructive(c, fmt.Sprintf("Delete %d trash entries forever?", len(purge))); err != nil {
+		return err
+	}
+	for _, entry := range purge {
+		if err := r.fileHandler.DeleteFolder(fmt.Sprintf("%s/%s", TrashFolder, entry.Id)); err != nil {
+			return err
+		}
+	}
+	fmt.Printf("%d trash entries purged\n", len(purge))
+	return nil
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/cryptvault-cloud/vault-cli/logger"
+	"github.com/urfave/cli/v2"
+)
+
+// watchedValue is one value polled by watch and the file it is written to, if any.
+type watchedValue struct {
+	name   string
+	output string
+	hash   *[32]byte
+}
+
+func GetWatchCommand(pRunner *ProtectedRunner) *cli.Command {
+	return &cli.Command{
+		Name:        "watch",
+		Usage:       "Poll values and rewrite files or run a hook if they changed",
+		Description: "Each --out belongs to the --name at the same position and is replaced atomically. The hook runs with sh -c and gets the changed names comma separated at VAULT_CLI_WATCH_CHANGED.",
+		Action:      pRunner.Watch,
+		Flags: []cli.Flag{
+			&cli.StringSliceFlag{
+				Name:     CliWatchName,
+				EnvVars:  []string{getFlagEnvByFlagName("watch_" + CliWatchName)},
+				Usage:    "Value name something like VALUES.a.b, can be set multiple times",
+				Required: true,
+			},
+			&cli.DurationFlag{
+				Name:    CliWatchInterval,
+				EnvVars: []string{getFlagEnvByFlagName("watch_" + CliWatchInterval)},
+				Usage:   "Time between two polls",
+				Value:   30 * time.Second,
+			},
+			&cli.StringFlag{
+				Name:    CliWatchExec,
+				EnvVars: []string{getFlagEnvByFlagName("watch_" + CliWatchExec)},
+				Usage:   "Command to run if a value changed, f.e. \"systemctl reload app\"",
+			},
+			&cli.StringSliceFlag{
+				Name:    CliWatchOut,
+				EnvVars: []string{getFlagEnvByFlagName("watch_" + CliWatchOut)},
+				Usage:   "File to write the value to, one for each --name",
+			},
+			&cli.BoolFlag{
+				Name:  CliWatchOnce,
+				Usage: "Poll only once, f.e. to run it from cron",
+			},
+		},
+	}
+}
+
+func (r *ProtectedRunner) Watch(c *cli.Context) error {
+	log := logger.GetWithStructAndFunc("ProtectedRunner", "Watch")
+	names := c.StringSlice(CliWatchName)
+	outputs := c.StringSlice(CliWatchOut)
+	if len(outputs) > 0 && len(outputs) != len(names) {
+		return withExitCode(ExitUsage, fmt.Errorf("got %d --%s for %d --%s, expected one for each", len(outputs), CliWatchOut, len(names), CliWatchName))
+	}
+	interval := c.Duration(CliWatchInterval)
+	if interval <= 0 {
+		return withExitCode(ExitUsage, fmt.Errorf("--%s has to be greater than 0", CliWatchInterval))
+	}
+	watched := make([]*watchedValue, 0, len(names))
+	for i, name := range names {
+		w := &watchedValue{name: name}
+		if len(outputs) > 0 {
+			w.output = outputs[i]
+			// an existing file is the baseline, so a restart does not trigger the hook
+			if content, err := os.ReadFile(w.output); err == nil {
+				hash := sha256.Sum256(content)
+				w.hash = &hash
+			}
+		}
+		watched = append(watched, w)
+	}
+
+	ctx, stop := signal.NotifyContext(c.Context, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	for {
+		changed, err := r.pollWatched(watched)
+		if err != nil {
+			if c.Bool(CliWatchOnce) {
+				return err
+			}
+			log.Warnw("poll failed, retry at next interval", "error", err)
+		}
+		if len(changed) > 0 {
+			log.Infow("values changed", "names", changed)
+			if hook := c.String(CliWatchExec); hook != "" {
+				if err := runWatchHook(ctx, hook, changed); err != nil {
+					if c.Bool(CliWatchOnce) {
+						return err
+					}
+					log.Errorw("hook failed", "exec", hook, "error", err)
+				}
+			}
+		}
+		if c.Bool(CliWatchOnce) {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(interval):
+		}
+	}
+}
+
+// pollWatched fetches all watched values and returns the names of the changed ones.
+// A value seen for the first time is only a change if it is written to an output file,
+// so a missing or outdated file is created and the hook runs, without an output file the first poll only remembers it.
+func (r *ProtectedRunner) pollWatched(watched []*watchedValue) ([]string, error) {
+	var errs error
+	changed := make([]string, 0)
+	for _, w := range watched {
+		value, err := r.fetchValue(w.name)
+		if err != nil {
+			errs = errors.Join(errs, fmt.Errorf("%s: %w", w.name, err))
+			continue
+		}
+		hash := sha256.Sum256([]byte(value))
+		if w.hash != nil && *w.hash == hash {
+			continue
+		}
+		if w.output != "" {
+			if err := writeFileAtomic(w.output, []byte(value)); err != nil {
+				errs = errors.Join(errs, err)
+				continue
+			}
+		}
+		if w.hash != nil || w.output != "" {
+			changed = append(changed, w.name)
+		}
+		w.hash = &hash
+	}
+	return changed, errs
+}
+
+func runWatchHook(ctx context.Context, hook string, changed []string) error {
+	cmd := exec.CommandContext(ctx, "sh", "-c", hook)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.Env = append(os.Environ(), "VAULT_CLI_WATCH_CHANGED="+strings.Join(changed, ","))
+	return cmd.Run()
+}
+
+// writeFileAtomic writes content to a temporary file next to path and renames it,
+// so readers never see a partly written file.
+func writeFileAtomic(path string, content []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(content); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
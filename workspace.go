@@ -0,0 +1,141 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+
+	client "github.com/cryptvault-cloud/api"
+	"github.com/cryptvault-cloud/helper"
+)
+
+// OperatorIdentityName is used to reference the operator key of a vault
+// where otherwise a local identity name is expected.
+const OperatorIdentityName = "operator"
+
+// identityFolder returns the workspace folder of a local identity or the operator folder.
+func identityFolder(vaultName, identityName string) string {
+	if identityName == OperatorIdentityName {
+		return fmt.Sprintf("%s/operator", vaultName)
+	}
+	return fmt.Sprintf("%s/identity/%s", vaultName, identityName)
+}
+
+// validWorkspaceName checks that a vault or identity name is one folder of the workspace.
+// Names starting with a dot are reserved for folders like .trash.
+func validWorkspaceName(name string) error {
+	if name == "" || strings.ContainsAny(name, `/\`) || strings.HasPrefix(name, ".") {
+		return fmt.Errorf("invalid name %q", name)
+	}
+	return nil
+}
+
+// validWorkspaceFile checks that a relative file path stays below its folder.
+func validWorkspaceFile(filePath string) error {
+	if filePath == "" || strings.Contains(filePath, `\`) || path.IsAbs(filePath) || path.Clean(filePath) != filePath ||
+		filePath == ".." || strings.HasPrefix(filePath, "../") {
+		return fmt.Errorf("invalid file path %q", filePath)
+	}
+	return nil
+}
+
+// vaultIdByName reads the vault id saved at the local workspace of vaultName.
+func (r *Runner) vaultIdByName(vaultName string) (string, error) {
+	vaultId, err := r.fileHandler.ReadTextFile(fmt.Sprintf("%s/vaultId", vaultName))
+	if err != nil {
+		return "", fmt.Errorf("vault %s not found at local workspace: %w", vaultName, err)
+	}
+	return strings.TrimSpace(vaultId), nil
+}
+
+// vaultNameById returns the name of the local vault with vaultId.
+func (r *Runner) vaultNameById(vaultId string) (string, error) {
+	vaults, err := r.fileHandler.AvailableVaults()
+	if err != nil {
+		return "", err
+	}
+	for _, vaultName := range vaults {
+		if id, err := r.vaultIdByName(vaultName); err == nil && id == vaultId {
+			return vaultName, nil
+		}
+	}
+	return "", fmt.Errorf("vault %s not found at local workspace", vaultId)
+}
+
+// identityKey reads the private key of a local identity of vaultName.
+func (r *Runner) identityKey(vaultName, identityName string) (*ecdsa.PrivateKey, error) {
+	b64Key, err := r.fileHandler.ReadTextFile(fmt.Sprintf("%s/key", identityFolder(vaultName, identityName)))
+	if err != nil {
+		return nil, fmt.Errorf("identity %s of vault %s not found at local workspace: %w", identityName, vaultName, err)
+	}
+	return parsePrivateKey(b64Key)
+}
+
+// protectedApiByWorkspace returns a protected api for the given local vault and identity
+// together with the vault id and the identity id of the used key.
+func (r *Runner) protectedApiByWorkspace(vaultName, identityName string) (client.ProtectedApiHandler, string, string, error) {
+	vaultId, err := r.vaultIdByName(vaultName)
+	if err != nil {
+		return nil, "", "", err
+	}
+	key, err := r.identityKey(vaultName, identityName)
+	if err != nil {
+		return nil, "", "", err
+	}
+	identityId, err := identityIdOfKey(&key.PublicKey, vaultId)
+	if err != nil {
+		return nil, "", "", err
+	}
+	return r.api.GetProtectedApi(key, vaultId), vaultId, identityId, nil
+}
+
+func identityIdOfKey(pubKey *ecdsa.PublicKey, vaultId string) (string, error) {
+	b64PubKey, err := helper.NewBase64PublicPem(pubKey)
+	if err != nil {
+		return "", err
+	}
+	return b64PubKey.GetIdentityId(vaultId)
+}
+
+// matchValueName reports whether name matches pattern.
+// Like right patterns a "*" matches exactly one token and a ">" matches all following tokens.
+func matchValueName(pattern, name string) bool {
+	patternTokens := strings.Split(pattern, ".")
+	nameTokens := strings.Split(name, ".")
+	for i, p := range patternTokens {
+		if p == ">" {
+			return len(nameTokens) > i
+		}
+		if i >= len(nameTokens) {
+			return false
+		}
+		if p != "*" && p != nameTokens[i] {
+			return false
+		}
+	}
+	return len(patternTokens) == len(nameTokens)
+}
+
+// localIdentities returns the names of all identities saved at <vault>/identity.
+func (r *Runner) localIdentities(vaultName string) ([]string, error) {
+	files, err := r.fileHandler.ListFiles(fmt.Sprintf("%s/identity", vaultName))
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return []string{}, nil
+		}
+		return nil, err
+	}
+	result := make([]string, 0)
+	for _, f := range files {
+		name, file, found := strings.Cut(f, "/")
+		if found && file == "key" {
+			result = append(result, name)
+		}
+	}
+	sort.Strings(result)
+	return result, nil
+}